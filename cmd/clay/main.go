@@ -4,6 +4,7 @@ import (
 	"embed"
 	"github.com/go-go-golems/clay/cmd/clay/repo"
 	clay "github.com/go-go-golems/clay/pkg"
+	"github.com/go-go-golems/clay/pkg/sql"
 	"github.com/go-go-golems/glazed/pkg/cli"
 	"github.com/go-go-golems/glazed/pkg/help"
 	"github.com/spf13/cobra"
@@ -57,6 +58,60 @@ func main() {
 	cobra.CheckErr(err)
 	repoCmd.AddCommand(cmd)
 
+	importCommand, err := repo.NewImportCommand()
+	cobra.CheckErr(err)
+	cmd, err = sql.BuildCobraCommandWithSqletonMiddlewares(importCommand)
+	cobra.CheckErr(err)
+	repoCmd.AddCommand(cmd)
+
+	exportCommand, err := repo.NewExportCommand()
+	cobra.CheckErr(err)
+	cmd, err = sql.BuildCobraCommandWithSqletonMiddlewares(exportCommand)
+	cobra.CheckErr(err)
+	repoCmd.AddCommand(cmd)
+
+	conflictsCommand, err := repo.NewConflictsCommand()
+	cobra.CheckErr(err)
+	cmd, err = cli.BuildCobraCommandFromGlazeCommand(conflictsCommand)
+	cobra.CheckErr(err)
+	repoCmd.AddCommand(cmd)
+
+	searchCommand, err := repo.NewSearchCommand()
+	cobra.CheckErr(err)
+	cmd, err = cli.BuildCobraCommandFromGlazeCommand(searchCommand)
+	cobra.CheckErr(err)
+	repoCmd.AddCommand(cmd)
+
+	bundleCommand, err := repo.NewBundleCommand()
+	cobra.CheckErr(err)
+	cmd, err = cli.BuildCobraCommandFromGlazeCommand(bundleCommand)
+	cobra.CheckErr(err)
+	repoCmd.AddCommand(cmd)
+
+	verifyCommand, err := repo.NewVerifyCommand()
+	cobra.CheckErr(err)
+	cmd, err = cli.BuildCobraCommandFromGlazeCommand(verifyCommand)
+	cobra.CheckErr(err)
+	repoCmd.AddCommand(cmd)
+
+	lintCommand, err := repo.NewLintCommand()
+	cobra.CheckErr(err)
+	cmd, err = cli.BuildCobraCommandFromGlazeCommand(lintCommand)
+	cobra.CheckErr(err)
+	repoCmd.AddCommand(cmd)
+
+	errorsCommand, err := repo.NewErrorsCommand()
+	cobra.CheckErr(err)
+	cmd, err = cli.BuildCobraCommandFromGlazeCommand(errorsCommand)
+	cobra.CheckErr(err)
+	repoCmd.AddCommand(cmd)
+
+	whichCommand, err := repo.NewWhichCommand()
+	cobra.CheckErr(err)
+	cmd, err = cli.BuildCobraCommandFromGlazeCommand(whichCommand)
+	cobra.CheckErr(err)
+	repoCmd.AddCommand(cmd)
+
 	err = rootCmd.Execute()
 	cobra.CheckErr(err)
 }
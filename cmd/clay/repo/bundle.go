@@ -0,0 +1,97 @@
+package repo
+
+import (
+	"context"
+	"github.com/go-go-golems/clay/pkg/bundle"
+	cmds2 "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+type BundleCommand struct {
+	*cmds.CommandDescription
+}
+
+var _ cmds.GlazeCommand = (*BundleCommand)(nil)
+
+func NewBundleCommand(options ...cmds.CommandDescriptionOption) (*BundleCommand, error) {
+	glazeParameterLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, err
+	}
+
+	options = append(options,
+		cmds.WithShort("Bundle command directories into an archive with a checksum manifest"),
+		cmds.WithLong("Writes the command files and doc/ help sections of the given directories "+
+			"to a .zip, .tar.gz or .tgz archive, along with a "+bundle.ManifestFileName+" manifest listing "+
+			"each command's path, parents, source file and SHA-256. Use 'clay repo verify' to check a bundle "+
+			"against its manifest."),
+		cmds.WithArguments(
+			parameters.NewParameterDefinition(
+				"output",
+				parameters.ParameterTypeString,
+				parameters.WithHelp("The archive to write (.zip, .tar.gz or .tgz)"),
+				parameters.WithRequired(true),
+			),
+			parameters.NewParameterDefinition(
+				"directories",
+				parameters.ParameterTypeStringList,
+				parameters.WithHelp("The command directories to bundle"),
+				parameters.WithRequired(true),
+			),
+		),
+		cmds.WithLayersList(glazeParameterLayer),
+	)
+
+	return &BundleCommand{
+		CommandDescription: cmds.NewCommandDescription("bundle", options...),
+	}, nil
+}
+
+type BundleSettings struct {
+	Output      string   `glazed.parameter:"output"`
+	Directories []string `glazed.parameter:"directories"`
+}
+
+func (c *BundleCommand) RunIntoGlazeProcessor(ctx context.Context, parsedLayers *layers.ParsedLayers, gp middlewares.Processor) error {
+	s := &BundleSettings{}
+	d := parsedLayers.GetDefaultParameterLayer()
+	err := d.InitializeStruct(s)
+	if err != nil {
+		return err
+	}
+
+	if !cmds2.IsArchive(s.Output) {
+		return errors.Errorf("unsupported archive format %s, use .zip, .tar.gz or .tgz", s.Output)
+	}
+
+	b, err := bundle.NewBundle(cmds2.NewRawCommandLoader(), s.Directories)
+	if err != nil {
+		return err
+	}
+	err = b.WriteArchive(s.Output)
+	if err != nil {
+		return err
+	}
+
+	for _, command := range b.Manifest.Commands {
+		row := types.NewRow(
+			types.MRP("path", command.Path),
+			types.MRP("parents", strings.Join(command.Parents, " ")),
+			types.MRP("source", command.Source),
+			types.MRP("sha256", command.SHA256),
+		)
+		err = gp.AddRow(ctx, row)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package repo
+
+import (
+	"context"
+	cmds2 "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/go-go-golems/clay/pkg/repositories"
+	"github.com/go-go-golems/clay/pkg/repositories/fs"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"strings"
+)
+
+type ConflictsCommand struct {
+	*cmds.CommandDescription
+}
+
+var _ cmds.GlazeCommand = (*ConflictsCommand)(nil)
+
+func NewConflictsCommand(options ...cmds.CommandDescriptionOption) (*ConflictsCommand, error) {
+	glazeParameterLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, err
+	}
+
+	options = append(options,
+		cmds.WithShort("List the commands shadowed when stacking command directories"),
+		cmds.WithLong("Directories are given in increasing order of priority: "+
+			"commands in later directories shadow the ones in earlier directories."),
+		cmds.WithArguments(
+			parameters.NewParameterDefinition(
+				"directories",
+				parameters.ParameterTypeStringList,
+				parameters.WithHelp("The command directories, from lowest to highest priority"),
+				parameters.WithRequired(true),
+			),
+		),
+		cmds.WithLayersList(glazeParameterLayer),
+	)
+
+	return &ConflictsCommand{
+		CommandDescription: cmds.NewCommandDescription("conflicts", options...),
+	}, nil
+}
+
+type ConflictsSettings struct {
+	Directories []string `glazed.parameter:"directories"`
+}
+
+func (c *ConflictsCommand) RunIntoGlazeProcessor(ctx context.Context, parsedLayers *layers.ParsedLayers, gp middlewares.Processor) error {
+	s := &ConflictsSettings{}
+	d := parsedLayers.GetDefaultParameterLayer()
+	err := d.InitializeStruct(s)
+	if err != nil {
+		return err
+	}
+
+	layers_ := []repositories.OverlayLayer{}
+	for _, directory := range s.Directories {
+		repository := fs.NewRepository(
+			fs.WithFSLoader(cmds2.NewRawCommandLoader()),
+			fs.WithDirectories([]string{directory}),
+		)
+		err = repository.LoadCommands()
+		if err != nil {
+			return err
+		}
+		layers_ = append(layers_, repositories.OverlayLayer{
+			Name:       directory,
+			Repository: repository,
+		})
+	}
+
+	overlay := repositories.NewOverlayRepository(layers_...)
+	for _, conflict := range overlay.Conflicts() {
+		for _, shadowed := range conflict.Shadowed {
+			row := types.NewRow(
+				types.MRP("path", strings.Join(conflict.Path, " ")),
+				types.MRP("layer", conflict.Winner.Layer),
+				types.MRP("source", cmds2.CommandSource(conflict.Winner.Command)),
+				types.MRP("shadowed_layer", shadowed.Layer),
+				types.MRP("shadowed_source", cmds2.CommandSource(shadowed.Command)),
+			)
+			err = gp.AddRow(ctx, row)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package repo
+
+import (
+	"context"
+	cmds2 "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/help"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+)
+
+type ErrorsCommand struct {
+	*cmds.CommandDescription
+}
+
+var _ cmds.GlazeCommand = (*ErrorsCommand)(nil)
+
+func NewErrorsCommand(options ...cmds.CommandDescriptionOption) (*ErrorsCommand, error) {
+	glazeParameterLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, err
+	}
+
+	options = append(options,
+		cmds.WithShort("List the files that can't be loaded from command repositories"),
+		cmds.WithLong("Loads the repositories like an application would on startup, "+
+			"and lists the files and help sections that were skipped because they could not be loaded."),
+		cmds.WithArguments(
+			parameters.NewParameterDefinition(
+				"repositories",
+				parameters.ParameterTypeStringList,
+				parameters.WithHelp("The command directories or archives to load"),
+				parameters.WithRequired(true),
+			),
+		),
+		cmds.WithLayersList(glazeParameterLayer),
+	)
+
+	return &ErrorsCommand{
+		CommandDescription: cmds.NewCommandDescription("errors", options...),
+	}, nil
+}
+
+type ErrorsSettings struct {
+	Repositories []string `glazed.parameter:"repositories"`
+}
+
+func (c *ErrorsCommand) RunIntoGlazeProcessor(ctx context.Context, parsedLayers *layers.ParsedLayers, gp middlewares.Processor) error {
+	s := &ErrorsSettings{}
+	d := parsedLayers.GetDefaultParameterLayer()
+	err := d.InitializeStruct(s)
+	if err != nil {
+		return err
+	}
+
+	locations := cmds2.NewCommandLocations(
+		cmds2.WithRepositories(s.Repositories...),
+		cmds2.WithLenient(true),
+	)
+	commandLoader := cmds2.NewCommandLoader[cmds.Command](locations)
+	_, _, err = commandLoader.LoadCommands(cmds2.NewRawCommandLoader(), help.NewHelpSystem())
+	if err != nil {
+		return err
+	}
+
+	for _, loadError := range commandLoader.LoadErrors() {
+		row := types.NewRow(
+			types.MRP("file", loadError.File),
+			types.MRP("line", loadError.Line),
+			types.MRP("error", loadError.Err.Error()),
+		)
+		err = gp.AddRow(ctx, row)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
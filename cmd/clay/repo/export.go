@@ -0,0 +1,149 @@
+package repo
+
+import (
+	"context"
+	cmds2 "github.com/go-go-golems/clay/pkg/cmds"
+	sql2 "github.com/go-go-golems/clay/pkg/repositories/sql"
+	"github.com/go-go-golems/clay/pkg/sql"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type ExportCommand struct {
+	*cmds.CommandDescription
+}
+
+var _ cmds.GlazeCommand = (*ExportCommand)(nil)
+
+func NewExportCommand(options ...cmds.CommandDescriptionOption) (*ExportCommand, error) {
+	glazeParameterLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, err
+	}
+	sqlConnectionParameterLayer, err := sql.NewSqlConnectionParameterLayer()
+	if err != nil {
+		return nil, err
+	}
+	dbtParameterLayer, err := sql.NewDbtParameterLayer()
+	if err != nil {
+		return nil, err
+	}
+
+	options = append(options,
+		cmds.WithShort("Export the commands stored in a database into a command directory"),
+		cmds.WithFlags(
+			parameters.NewParameterDefinition(
+				"table",
+				parameters.ParameterTypeString,
+				parameters.WithHelp("The table the commands are stored in"),
+				parameters.WithDefault(sql2.DefaultTableName),
+			),
+		),
+		cmds.WithArguments(
+			parameters.NewParameterDefinition(
+				"output",
+				parameters.ParameterTypeString,
+				parameters.WithHelp("The directory to write the commands to"),
+				parameters.WithRequired(true),
+			),
+		),
+		cmds.WithLayersList(glazeParameterLayer, sqlConnectionParameterLayer, dbtParameterLayer),
+	)
+
+	return &ExportCommand{
+		CommandDescription: cmds.NewCommandDescription("export", options...),
+	}, nil
+}
+
+type ExportSettings struct {
+	Output string `glazed.parameter:"output"`
+	Table  string `glazed.parameter:"table"`
+}
+
+func (c *ExportCommand) RunIntoGlazeProcessor(ctx context.Context, parsedLayers *layers.ParsedLayers, gp middlewares.Processor) error {
+	s := &ExportSettings{}
+	d := parsedLayers.GetDefaultParameterLayer()
+	err := d.InitializeStruct(s)
+	if err != nil {
+		return err
+	}
+
+	db, err := sql.OpenDatabaseFromDefaultSqlConnectionLayer(parsedLayers)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	repository := sql2.NewRepository(db, sql2.WithTable(s.Table))
+	commands, err := repository.LoadCommands(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, command := range commands {
+		fileName, err := exportCommand(s.Output, command)
+		if err != nil {
+			return err
+		}
+
+		row := commandToRow(command)
+		row.Set("file", fileName)
+		err = gp.AddRow(ctx, row)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exportCommand writes the YAML of command to output/parents.../name.yaml,
+// which is where the repository loader expects it.
+func exportCommand(output string, command cmds.Command) (string, error) {
+	var name string
+	var parents []string
+	if alias_, ok := command.(*alias.CommandAlias); ok {
+		name = alias_.Name
+		parents = alias_.Parents
+	} else {
+		description := command.Description()
+		name = description.Name
+		parents = description.Parents
+	}
+
+	// the database content is not trusted to stay inside the output directory
+	for _, p := range append(append([]string{}, parents...), name) {
+		if p == "" || p == "." || p == ".." || strings.ContainsAny(p, `/\`) {
+			return "", errors.Errorf("invalid path component %q for command %s", p, name)
+		}
+	}
+
+	dir := filepath.Join(append([]string{output}, parents...)...)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := cmds2.CommandToYAML(command)
+	if err != nil {
+		return "", err
+	}
+
+	fileName := filepath.Join(dir, name+".yaml")
+	err = os.WriteFile(fileName, content, 0644)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not write %s", fileName)
+	}
+
+	return fileName, nil
+}
@@ -0,0 +1,139 @@
+package repo
+
+import (
+	"context"
+	cmds2 "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/go-go-golems/clay/pkg/repositories/fs"
+	sql2 "github.com/go-go-golems/clay/pkg/repositories/sql"
+	"github.com/go-go-golems/clay/pkg/sql"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"strings"
+)
+
+type ImportCommand struct {
+	*cmds.CommandDescription
+}
+
+var _ cmds.GlazeCommand = (*ImportCommand)(nil)
+
+func NewImportCommand(options ...cmds.CommandDescriptionOption) (*ImportCommand, error) {
+	glazeParameterLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, err
+	}
+	sqlConnectionParameterLayer, err := sql.NewSqlConnectionParameterLayer()
+	if err != nil {
+		return nil, err
+	}
+	dbtParameterLayer, err := sql.NewDbtParameterLayer()
+	if err != nil {
+		return nil, err
+	}
+
+	options = append(options,
+		cmds.WithShort("Import a command directory or individual files into a database"),
+		cmds.WithFlags(
+			parameters.NewParameterDefinition(
+				"table",
+				parameters.ParameterTypeString,
+				parameters.WithHelp("The table to store the commands in"),
+				parameters.WithDefault(sql2.DefaultTableName),
+			),
+			parameters.NewParameterDefinition(
+				"exclude",
+				parameters.ParameterTypeStringList,
+				parameters.WithHelp("Patterns of the files to skip, in .clayignore syntax"),
+			),
+		),
+		cmds.WithArguments(
+			parameters.NewParameterDefinition(
+				"inputs",
+				parameters.ParameterTypeStringList,
+				parameters.WithHelp("The command directory or individual files to import"),
+				parameters.WithRequired(true),
+			),
+		),
+		cmds.WithLayersList(glazeParameterLayer, sqlConnectionParameterLayer, dbtParameterLayer),
+	)
+
+	return &ImportCommand{
+		CommandDescription: cmds.NewCommandDescription("import", options...),
+	}, nil
+}
+
+type ImportSettings struct {
+	Inputs  []string `glazed.parameter:"inputs"`
+	Table   string   `glazed.parameter:"table"`
+	Exclude []string `glazed.parameter:"exclude"`
+}
+
+func (c *ImportCommand) RunIntoGlazeProcessor(ctx context.Context, parsedLayers *layers.ParsedLayers, gp middlewares.Processor) error {
+	s := &ImportSettings{}
+	d := parsedLayers.GetDefaultParameterLayer()
+	err := d.InitializeStruct(s)
+	if err != nil {
+		return err
+	}
+
+	commands, err := fs.LoadCommandsFromInputs(cmds2.NewRawCommandLoader(), s.Inputs, s.Exclude...)
+	if err != nil {
+		return err
+	}
+
+	db, err := sql.OpenDatabaseFromDefaultSqlConnectionLayer(parsedLayers)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	repository := sql2.NewRepository(db, sql2.WithTable(s.Table))
+	err = repository.CreateTables(ctx)
+	if err != nil {
+		return err
+	}
+	err = repository.Add(ctx, commands...)
+	if err != nil {
+		return err
+	}
+
+	for _, command := range commands {
+		row := commandToRow(command)
+		err = gp.AddRow(ctx, row)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func commandToRow(command cmds.Command) types.Row {
+	// unresolved aliases don't have a description
+	if alias_, ok := command.(*alias.CommandAlias); ok {
+		return types.NewRow(
+			types.MRP("path", fullPath(alias_.Parents, alias_.Name)),
+			types.MRP("type", "alias"),
+			types.MRP("source", alias_.Source),
+		)
+	}
+
+	description := command.Description()
+	return types.NewRow(
+		types.MRP("path", fullPath(description.Parents, description.Name)),
+		types.MRP("type", "command"),
+		types.MRP("source", description.Source),
+	)
+}
+
+func fullPath(parents []string, name string) string {
+	path := append([]string{}, parents...)
+	return strings.Join(append(path, name), " ")
+}
@@ -0,0 +1,91 @@
+package repo
+
+import (
+	"context"
+	"github.com/go-go-golems/clay/pkg/lint"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/pkg/errors"
+)
+
+type LintCommand struct {
+	*cmds.CommandDescription
+}
+
+var _ cmds.GlazeCommand = (*LintCommand)(nil)
+
+func NewLintCommand(options ...cmds.CommandDescriptionOption) (*LintCommand, error) {
+	glazeParameterLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, err
+	}
+
+	options = append(options,
+		cmds.WithShort("Check command directories for errors"),
+		cmds.WithLong("Reports duplicate commands, aliases of missing commands, alias cycles, invalid flags and "+
+			"arguments, and parents that clash with the directory layout. "+
+			"Exits with a non-zero status if any error is found."),
+		cmds.WithArguments(
+			parameters.NewParameterDefinition(
+				"directories",
+				parameters.ParameterTypeStringList,
+				parameters.WithHelp("The command directories to check"),
+				parameters.WithRequired(true),
+			),
+		),
+		cmds.WithLayersList(glazeParameterLayer),
+	)
+
+	return &LintCommand{
+		CommandDescription: cmds.NewCommandDescription("lint", options...),
+	}, nil
+}
+
+type LintSettings struct {
+	Directories []string `glazed.parameter:"directories"`
+}
+
+func (c *LintCommand) RunIntoGlazeProcessor(ctx context.Context, parsedLayers *layers.ParsedLayers, gp middlewares.Processor) error {
+	s := &LintSettings{}
+	d := parsedLayers.GetDefaultParameterLayer()
+	err := d.InitializeStruct(s)
+	if err != nil {
+		return err
+	}
+
+	problems, err := lint.Lint(s.Directories)
+	if err != nil {
+		return err
+	}
+
+	errorCount := 0
+	for _, problem := range problems {
+		if problem.Severity == lint.SeverityError {
+			errorCount++
+		}
+		row := types.NewRow(
+			types.MRP("file", problem.File),
+			types.MRP("severity", string(problem.Severity)),
+			types.MRP("message", problem.Message),
+		)
+		err = gp.AddRow(ctx, row)
+		if err != nil {
+			return err
+		}
+	}
+
+	if errorCount > 0 {
+		// flush the problems before exiting with an error
+		err = gp.Close(ctx)
+		if err != nil {
+			return err
+		}
+		return errors.Errorf("found %d errors", errorCount)
+	}
+
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	cmds2 "github.com/go-go-golems/clay/pkg/cmds"
 	"github.com/go-go-golems/clay/pkg/repositories/fs"
+	"github.com/go-go-golems/clay/pkg/repositories/plugin"
 	"github.com/go-go-golems/glazed/pkg/cmds"
 	"github.com/go-go-golems/glazed/pkg/cmds/layers"
 	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
@@ -24,13 +25,25 @@ func NewListCommand(options ...cmds.CommandDescriptionOption) (*ListCommand, err
 	}
 
 	options = append(options,
-		cmds.WithShort("Import a command directory or individual files into a database"),
-		cmds.WithFlags(),
+		cmds.WithShort("List the commands in a command directory or individual files"),
+		cmds.WithFlags(
+			parameters.NewParameterDefinition(
+				"exclude",
+				parameters.ParameterTypeStringList,
+				parameters.WithHelp("Patterns of the files to skip, in .clayignore syntax"),
+			),
+			parameters.NewParameterDefinition(
+				"plugins",
+				parameters.ParameterTypeBool,
+				parameters.WithHelp("Also list the clay-* plugin executables found on PATH"),
+				parameters.WithDefault(false),
+			),
+		),
 		cmds.WithArguments(
 			parameters.NewParameterDefinition(
 				"inputs",
 				parameters.ParameterTypeStringList,
-				parameters.WithHelp("The command directory or individual files to import"),
+				parameters.WithHelp("The command directory or individual files to list"),
 				parameters.WithRequired(true),
 			),
 		),
@@ -43,7 +56,9 @@ func NewListCommand(options ...cmds.CommandDescriptionOption) (*ListCommand, err
 }
 
 type ListSettings struct {
-	Inputs []string `glazed.parameter:"inputs"`
+	Inputs  []string `glazed.parameter:"inputs"`
+	Exclude []string `glazed.parameter:"exclude"`
+	Plugins bool     `glazed.parameter:"plugins"`
 }
 
 func (c *ListCommand) RunIntoGlazeProcessor(ctx context.Context, parsedLayers *layers.ParsedLayers, gp middlewares.Processor) error {
@@ -54,10 +69,17 @@ func (c *ListCommand) RunIntoGlazeProcessor(ctx context.Context, parsedLayers *l
 		return err
 	}
 
-	commands, err := fs.LoadCommandsFromInputs(cmds2.NewRawCommandLoader(), s.Inputs)
+	commands, err := fs.LoadCommandsFromInputs(cmds2.NewRawCommandLoader(), s.Inputs, s.Exclude...)
 	if err != nil {
 		return err
 	}
+	if s.Plugins {
+		pluginCommands, err := plugin.NewRepository("clay").LoadCommands(ctx)
+		if err != nil {
+			return err
+		}
+		commands = append(commands, pluginCommands...)
+	}
 
 	err2 := cmds2.ListCommandsIntoProcessor(ctx, commands, gp)
 	if err2 != nil {
@@ -0,0 +1,123 @@
+package repo
+
+import (
+	"context"
+	cmds2 "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/go-go-golems/clay/pkg/repositories/fs"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"strings"
+)
+
+type SearchCommand struct {
+	*cmds.CommandDescription
+}
+
+var _ cmds.GlazeCommand = (*SearchCommand)(nil)
+
+func NewSearchCommand(options ...cmds.CommandDescriptionOption) (*SearchCommand, error) {
+	glazeParameterLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, err
+	}
+
+	options = append(options,
+		cmds.WithShort("Search the commands in command directories or individual files"),
+		cmds.WithLong("Commands are ranked by fuzzy matching the query against their full path, "+
+			"short and long help, flag names and argument names."),
+		cmds.WithFlags(
+			parameters.NewParameterDefinition(
+				"parent",
+				parameters.ParameterTypeString,
+				parameters.WithHelp("Only search commands below this parent path (for example 'ops db')"),
+			),
+			parameters.NewParameterDefinition(
+				"source",
+				parameters.ParameterTypeString,
+				parameters.WithHelp("Only search commands loaded from this directory"),
+			),
+			parameters.NewParameterDefinition(
+				"limit",
+				parameters.ParameterTypeInteger,
+				parameters.WithHelp("Maximum number of results (0 for all)"),
+				parameters.WithDefault(20),
+			),
+			parameters.NewParameterDefinition(
+				"exclude",
+				parameters.ParameterTypeStringList,
+				parameters.WithHelp("Patterns of the files to skip, in .clayignore syntax"),
+			),
+		),
+		cmds.WithArguments(
+			parameters.NewParameterDefinition(
+				"query",
+				parameters.ParameterTypeString,
+				parameters.WithHelp("The search query"),
+				parameters.WithRequired(true),
+			),
+			parameters.NewParameterDefinition(
+				"inputs",
+				parameters.ParameterTypeStringList,
+				parameters.WithHelp("The command directories or individual files to search"),
+				parameters.WithRequired(true),
+			),
+		),
+		cmds.WithLayersList(glazeParameterLayer),
+	)
+
+	return &SearchCommand{
+		CommandDescription: cmds.NewCommandDescription("search", options...),
+	}, nil
+}
+
+type SearchSettings struct {
+	Query   string   `glazed.parameter:"query"`
+	Inputs  []string `glazed.parameter:"inputs"`
+	Parent  string   `glazed.parameter:"parent"`
+	Source  string   `glazed.parameter:"source"`
+	Limit   int      `glazed.parameter:"limit"`
+	Exclude []string `glazed.parameter:"exclude"`
+}
+
+func (c *SearchCommand) RunIntoGlazeProcessor(ctx context.Context, parsedLayers *layers.ParsedLayers, gp middlewares.Processor) error {
+	s := &SearchSettings{}
+	d := parsedLayers.GetDefaultParameterLayer()
+	err := d.InitializeStruct(s)
+	if err != nil {
+		return err
+	}
+
+	repository, err := fs.LoadRepositoryFromInputs(cmds2.NewRawCommandLoader(), s.Inputs, s.Exclude...)
+	if err != nil {
+		return err
+	}
+
+	searchOptions := []fs.SearchOption{fs.WithSearchLimit(s.Limit)}
+	if s.Parent != "" {
+		searchOptions = append(searchOptions, fs.WithParentPrefix(strings.Fields(strings.ReplaceAll(s.Parent, "/", " "))...))
+	}
+	if s.Source != "" {
+		searchOptions = append(searchOptions, fs.WithSourceDirectory(s.Source))
+	}
+
+	for _, result := range repository.Search(s.Query, searchOptions...) {
+		description := result.Command.Description()
+		row := types.NewRow(
+			types.MRP("path", strings.Join(result.Path, " ")),
+			types.MRP("score", result.Score),
+			types.MRP("matched", strings.Join(result.MatchedFields, ",")),
+			types.MRP("short", description.Short),
+			types.MRP("source", description.Source),
+		)
+		err = gp.AddRow(ctx, row)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
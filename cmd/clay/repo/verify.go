@@ -0,0 +1,90 @@
+package repo
+
+import (
+	"context"
+	"github.com/go-go-golems/clay/pkg/bundle"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/pkg/errors"
+)
+
+type VerifyCommand struct {
+	*cmds.CommandDescription
+}
+
+var _ cmds.GlazeCommand = (*VerifyCommand)(nil)
+
+func NewVerifyCommand(options ...cmds.CommandDescriptionOption) (*VerifyCommand, error) {
+	glazeParameterLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, err
+	}
+
+	options = append(options,
+		cmds.WithShort("Verify a command bundle against its manifest"),
+		cmds.WithLong("Checks an archived or extracted bundle created by 'clay repo bundle' against its "+
+			"manifest, and lists missing, modified and unlisted files. Exits with a non-zero status "+
+			"if any problem is found."),
+		cmds.WithArguments(
+			parameters.NewParameterDefinition(
+				"bundle",
+				parameters.ParameterTypeString,
+				parameters.WithHelp("The bundle archive or extracted directory"),
+				parameters.WithRequired(true),
+			),
+		),
+		cmds.WithLayersList(glazeParameterLayer),
+	)
+
+	return &VerifyCommand{
+		CommandDescription: cmds.NewCommandDescription("verify", options...),
+	}, nil
+}
+
+type VerifySettings struct {
+	Bundle string `glazed.parameter:"bundle"`
+}
+
+func (c *VerifyCommand) RunIntoGlazeProcessor(ctx context.Context, parsedLayers *layers.ParsedLayers, gp middlewares.Processor) error {
+	s := &VerifySettings{}
+	d := parsedLayers.GetDefaultParameterLayer()
+	err := d.InitializeStruct(s)
+	if err != nil {
+		return err
+	}
+
+	f, err := bundle.Open(s.Bundle)
+	if err != nil {
+		return err
+	}
+	problems, err := bundle.Verify(f)
+	if err != nil {
+		return err
+	}
+
+	for _, problem := range problems {
+		row := types.NewRow(
+			types.MRP("file", problem.File),
+			types.MRP("problem", problem.Message),
+		)
+		err = gp.AddRow(ctx, row)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(problems) > 0 {
+		// flush the problems before exiting with an error
+		err = gp.Close(ctx)
+		if err != nil {
+			return err
+		}
+		return errors.Errorf("bundle %s does not match its manifest (%d problems)", s.Bundle, len(problems))
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package repo
+
+import (
+	"context"
+	cmds2 "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/go-go-golems/clay/pkg/repositories"
+	"github.com/go-go-golems/clay/pkg/repositories/fs"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"strings"
+	"time"
+)
+
+type WhichCommand struct {
+	*cmds.CommandDescription
+}
+
+var _ cmds.GlazeCommand = (*WhichCommand)(nil)
+
+func NewWhichCommand(options ...cmds.CommandDescriptionOption) (*WhichCommand, error) {
+	glazeParameterLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, err
+	}
+
+	options = append(options,
+		cmds.WithShort("Show where commands are loaded from"),
+		cmds.WithLong("Lists the definition of every command path that is in use, followed by the "+
+			"definitions it shadows. Repositories are given in increasing order of priority: "+
+			"commands in later repositories shadow the ones in earlier repositories.\n\n"+
+			"Command paths are given with spaces or slashes, for example 'ops users' or ops/users."),
+		cmds.WithFlags(
+			parameters.NewParameterDefinition(
+				"repositories",
+				parameters.ParameterTypeStringList,
+				parameters.WithHelp("The command directories, files or archives, from lowest to highest priority"),
+				parameters.WithRequired(true),
+			),
+			parameters.NewParameterDefinition(
+				"exclude",
+				parameters.ParameterTypeStringList,
+				parameters.WithHelp("Patterns of the files to skip, in .clayignore syntax"),
+			),
+		),
+		cmds.WithArguments(
+			parameters.NewParameterDefinition(
+				"paths",
+				parameters.ParameterTypeStringList,
+				parameters.WithHelp("The paths of the commands to look up"),
+				parameters.WithRequired(true),
+			),
+		),
+		cmds.WithLayersList(glazeParameterLayer),
+	)
+
+	return &WhichCommand{
+		CommandDescription: cmds.NewCommandDescription("which", options...),
+	}, nil
+}
+
+type WhichSettings struct {
+	Paths        []string `glazed.parameter:"paths"`
+	Repositories []string `glazed.parameter:"repositories"`
+	Exclude      []string `glazed.parameter:"exclude"`
+}
+
+func (c *WhichCommand) RunIntoGlazeProcessor(ctx context.Context, parsedLayers *layers.ParsedLayers, gp middlewares.Processor) error {
+	s := &WhichSettings{}
+	d := parsedLayers.GetDefaultParameterLayer()
+	err := d.InitializeStruct(s)
+	if err != nil {
+		return err
+	}
+
+	layers_ := []repositories.OverlayLayer{}
+	for _, repository := range s.Repositories {
+		r, err := fs.LoadRepositoryFromInputs(cmds2.NewRawCommandLoader(), []string{repository}, s.Exclude...)
+		if err != nil {
+			return err
+		}
+		layers_ = append(layers_, repositories.OverlayLayer{
+			Name:       repository,
+			Repository: r,
+		})
+	}
+	overlay := repositories.NewOverlayRepository(layers_...)
+
+	for _, path_ := range s.Paths {
+		path := strings.FieldsFunc(path_, func(r rune) bool {
+			return r == ' ' || r == '/'
+		})
+
+		entries := overlay.Which(path)
+		if len(entries) == 0 {
+			row := types.NewRow(
+				types.MRP("path", strings.Join(path, " ")),
+				types.MRP("status", "not found"),
+			)
+			err = gp.AddRow(ctx, row)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		for i, entry := range entries {
+			status := "active"
+			if i > 0 {
+				status = "shadowed"
+			}
+			row := types.NewRow(
+				types.MRP("path", strings.Join(path, " ")),
+				types.MRP("status", status),
+				types.MRP("layer", entry.Layer),
+			)
+			if provenance, ok := getProvenance(entry); ok {
+				row.Set("kind", string(provenance.Kind))
+				row.Set("location", provenance.Location)
+				row.Set("file", provenance.Path)
+				row.Set("loaded_at", provenance.LoadedAt.Format(time.RFC3339))
+				row.Set("hash", provenance.Hash)
+			} else {
+				row.Set("file", cmds2.CommandSource(entry.Command))
+			}
+			err = gp.AddRow(ctx, row)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// getProvenance returns the provenance of the command of entry, as recorded by its layer.
+func getProvenance(entry repositories.OverlayEntry) (*cmds2.Provenance, bool) {
+	r, ok := entry.Repository.(*fs.Repository)
+	if !ok {
+		return nil, false
+	}
+	return r.GetProvenance(entry.Command)
+}
@@ -0,0 +1,322 @@
+package bundle
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	claycmds "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/go-go-golems/clay/pkg/repositories/fs"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/go-go-golems/glazed/pkg/cmds/loaders"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"io"
+	fs2 "io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ManifestFileName is the name of the manifest at the root of a bundle.
+// It is a hidden file, so that it is skipped when loading the commands of the bundle.
+const ManifestFileName = ".clay-manifest.yaml"
+
+// ManifestCommand describes a command or alias contained in a bundle.
+type ManifestCommand struct {
+	Path    string   `yaml:"path"`
+	Name    string   `yaml:"name"`
+	Parents []string `yaml:"parents,omitempty"`
+	// Source is the path of the file defining the command, relative to the bundle root
+	Source string `yaml:"source"`
+	SHA256 string `yaml:"sha256"`
+}
+
+// ManifestFile is a file contained in a bundle, either a command or a help section.
+type ManifestFile struct {
+	Path   string `yaml:"path"`
+	SHA256 string `yaml:"sha256"`
+}
+
+// A Manifest lists the commands and files of a bundle along with their SHA-256 checksums,
+// so that an extracted or archived bundle can be checked for tampering or partial copies.
+type Manifest struct {
+	Commands []ManifestCommand `yaml:"commands"`
+	Files    []ManifestFile    `yaml:"files"`
+}
+
+// A Bundle is a set of command files and help sections collected from one or more
+// repository directories, ready to be written to an archive.
+type Bundle struct {
+	Manifest *Manifest
+	// Files maps the path of a file in the bundle to its content
+	Files map[string][]byte
+}
+
+func (b *Bundle) addFile(path string, content []byte) error {
+	if previous, ok := b.Files[path]; ok && claycmds.HashContent(previous) != claycmds.HashContent(content) {
+		return errors.Errorf("conflicting versions of %s", path)
+	}
+	b.Files[path] = content
+	return nil
+}
+
+// NewBundle collects the commands that loader can load from directories, as well as
+// their group files and the help sections in their doc/ directory.
+//
+// The directories are loaded into a single repository, so that aliases can extend commands
+// from another directory. Aliases whose command can't be found are an error, since they
+// would be missing from the bundle.
+//
+// Files keep their path relative to their repository directory, so that loading the bundle
+// yields the same commands as loading the directories. Two directories providing different
+// files at the same path is an error.
+func NewBundle(loader loaders.CommandLoader, directories []string) (*Bundle, error) {
+	ret := &Bundle{
+		Manifest: &Manifest{},
+		Files:    map[string][]byte{},
+	}
+
+	repository := fs.NewRepository(
+		fs.WithFSLoader(loader),
+		fs.WithDirectories(append([]string{}, directories...)),
+	)
+	err := repository.LoadCommands()
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not load commands from %s", strings.Join(directories, ", "))
+	}
+	if pending := repository.PendingAliases(); len(pending) > 0 {
+		aliases := []string{}
+		for _, alias_ := range pending {
+			aliases = append(aliases, fmt.Sprintf("%s (alias for %s)",
+				strings.Join(claycmds.CommandPath(alias_), " "), alias_.AliasFor))
+		}
+		return nil, errors.Errorf("could not find the commands of aliases %s", strings.Join(aliases, ", "))
+	}
+	roots := repository.Directories
+
+	// addSource adds a file of the repository to the bundle, and returns its path in the bundle
+	addSource := func(source string) (string, []byte, error) {
+		root := fileRoot(roots, source)
+		rel, err := filepath.Rel(root, source)
+		if err != nil {
+			return "", nil, errors.Wrapf(err, "could not get path of %s", source)
+		}
+		content, err := os.ReadFile(source)
+		if err != nil {
+			return "", nil, err
+		}
+		path := filepath.ToSlash(rel)
+
+		// a file shadowed by another directory would be lost in the bundle
+		for _, other := range roots {
+			if other == root {
+				continue
+			}
+			otherContent, err := os.ReadFile(filepath.Join(other, rel))
+			if err == nil && claycmds.HashContent(otherContent) != claycmds.HashContent(content) {
+				return "", nil, errors.Errorf("conflicting versions of %s in %s and %s", path, root, other)
+			}
+		}
+
+		err = ret.addFile(path, content)
+		if err != nil {
+			return "", nil, err
+		}
+		return path, content, nil
+	}
+
+	for _, command := range repository.CollectCommands([]string{}, true) {
+		var name, source string
+		var parents []string
+		if alias_, ok := command.(*alias.CommandAlias); ok {
+			name, parents, source = alias_.Name, alias_.Parents, alias_.Source
+		} else {
+			description := command.Description()
+			name, parents, source = description.Name, description.Parents, description.Source
+		}
+
+		// files defining several commands are only added once, but all their commands are listed
+		path, content, err := addSource(source)
+		if err != nil {
+			return nil, err
+		}
+		ret.Manifest.Commands = append(ret.Manifest.Commands, ManifestCommand{
+			Path:    strings.Join(append(append([]string{}, parents...), name), " "),
+			Name:    name,
+			Parents: parents,
+			Source:  path,
+			SHA256:  claycmds.HashContent(content),
+		})
+	}
+
+	for _, group := range repository.Groups() {
+		_, _, err := addSource(group.Source)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, root := range roots {
+		docDir := filepath.Join(root, "doc")
+		if _, err := os.Stat(docDir); err != nil {
+			continue
+		}
+		err = fs2.WalkDir(os.DirFS(root), "doc", func(path string, d fs2.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			content, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(path)))
+			if err != nil {
+				return err
+			}
+			return ret.addFile(path, content)
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not collect help sections from %s", docDir)
+		}
+	}
+
+	for path, content := range ret.Files {
+		ret.Manifest.Files = append(ret.Manifest.Files, ManifestFile{
+			Path:   path,
+			SHA256: claycmds.HashContent(content),
+		})
+	}
+	sort.Slice(ret.Manifest.Files, func(i, j int) bool {
+		return ret.Manifest.Files[i].Path < ret.Manifest.Files[j].Path
+	})
+	sort.Slice(ret.Manifest.Commands, func(i, j int) bool {
+		return ret.Manifest.Commands[i].Path < ret.Manifest.Commands[j].Path
+	})
+
+	return ret, nil
+}
+
+// fileRoot returns the innermost of roots containing file.
+func fileRoot(roots []string, file string) string {
+	ret := ""
+	for _, root := range roots {
+		if strings.HasPrefix(file, root+string(filepath.Separator)) && len(root) > len(ret) {
+			ret = root
+		}
+	}
+	return ret
+}
+
+// WriteArchive writes the files of the bundle and its manifest to a .zip, .tar.gz or .tgz archive.
+func (b *Bundle) WriteArchive(fileName string) error {
+	manifest, err := yaml.Marshal(b.Manifest)
+	if err != nil {
+		return err
+	}
+
+	paths := []string{ManifestFileName}
+	for path := range b.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths[1:])
+	contents := func(path string) []byte {
+		if path == ManifestFileName {
+			return manifest
+		}
+		return b.Files[path]
+	}
+
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	lower := strings.ToLower(fileName)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		err = writeZip(f, paths, contents)
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		err = writeTarGz(f, paths, contents)
+	default:
+		return errors.Errorf("unsupported archive format %s, use .zip, .tar.gz or .tgz", fileName)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "could not write archive %s", fileName)
+	}
+
+	return f.Close()
+}
+
+func writeZip(w io.Writer, paths []string, contents func(string) []byte) error {
+	zw := zip.NewWriter(w)
+	for _, path := range paths {
+		fw, err := zw.Create(path)
+		if err != nil {
+			return err
+		}
+		_, err = fw.Write(contents(path))
+		if err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func writeTarGz(w io.Writer, paths []string, contents func(string) []byte) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, path := range paths {
+		content := contents(path)
+		err := tw.WriteHeader(&tar.Header{
+			Name:     path,
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			Size:     int64(len(content)),
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(content)
+		if err != nil {
+			return err
+		}
+	}
+	err := tw.Close()
+	if err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Open opens a bundle that is either an extracted directory or a .zip/.tar.gz archive.
+func Open(path string) (fs2.FS, error) {
+	s, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if s.IsDir() {
+		return os.DirFS(path), nil
+	}
+	if !claycmds.IsArchive(path) {
+		return nil, errors.Errorf("%s is neither a directory nor a bundle archive", path)
+	}
+	return claycmds.OpenArchive(path)
+}
+
+// ReadManifest reads the manifest at the root of a bundle.
+func ReadManifest(f fs2.FS) (*Manifest, error) {
+	content, err := fs2.ReadFile(f, ManifestFileName)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read bundle manifest")
+	}
+	ret := &Manifest{}
+	err = yaml.Unmarshal(content, ret)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse bundle manifest")
+	}
+	return ret, nil
+}
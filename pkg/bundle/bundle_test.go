@@ -0,0 +1,158 @@
+package bundle
+
+import (
+	claycmds "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	fs2 "io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, dir string, name string, content string) {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func newTestBundle(t *testing.T) *Bundle {
+	dir1 := t.TempDir()
+	writeTestFile(t, dir1, "ops/users.yaml", "name: users\nshort: List users\n")
+	writeTestFile(t, dir1, "doc/topics/01-ops.md", "---\nTitle: Ops\n---\n")
+	dir2 := t.TempDir()
+	writeTestFile(t, dir2, "ls.yaml", "name: ls\nshort: List\n")
+
+	b, err := NewBundle(claycmds.NewRawCommandLoader(), []string{dir1, dir2})
+	require.NoError(t, err)
+	return b
+}
+
+func TestNewBundle(t *testing.T) {
+	b := newTestBundle(t)
+
+	require.Len(t, b.Manifest.Commands, 2)
+	assert.Equal(t, "ls", b.Manifest.Commands[0].Path)
+	assert.Equal(t, "ls.yaml", b.Manifest.Commands[0].Source)
+	assert.Equal(t, "ops users", b.Manifest.Commands[1].Path)
+	assert.Equal(t, []string{"ops"}, b.Manifest.Commands[1].Parents)
+	assert.Equal(t, "ops/users.yaml", b.Manifest.Commands[1].Source)
+	assert.Equal(t, claycmds.HashContent([]byte("name: users\nshort: List users\n")), b.Manifest.Commands[1].SHA256)
+
+	paths := []string{}
+	for _, f := range b.Manifest.Files {
+		paths = append(paths, f.Path)
+	}
+	assert.Equal(t, []string{"doc/topics/01-ops.md", "ls.yaml", "ops/users.yaml"}, paths)
+}
+
+func TestConflictingFiles(t *testing.T) {
+	dir1 := t.TempDir()
+	writeTestFile(t, dir1, "ls.yaml", "name: ls\nshort: List\n")
+	dir2 := t.TempDir()
+	writeTestFile(t, dir2, "ls.yaml", "name: ls\nshort: List files\n")
+
+	_, err := NewBundle(claycmds.NewRawCommandLoader(), []string{dir1, dir2})
+	assert.Error(t, err)
+}
+
+func TestVerifyArchives(t *testing.T) {
+	b := newTestBundle(t)
+
+	for _, name := range []string{"pack.zip", "pack.tar.gz"} {
+		t.Run(name, func(t *testing.T) {
+			archive := filepath.Join(t.TempDir(), name)
+			require.NoError(t, b.WriteArchive(archive))
+
+			f, err := Open(archive)
+			require.NoError(t, err)
+			problems, err := Verify(f)
+			require.NoError(t, err)
+			assert.Empty(t, problems)
+
+			// the bundle can be loaded as a repository, without loading the manifest
+			commands, err := claycmds.LoadCommandsFromFS(f, ".", claycmds.NewRawCommandLoader(), nil, nil)
+			require.NoError(t, err)
+			assert.Len(t, commands, 2)
+		})
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	b := newTestBundle(t)
+
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "pack.tar.gz")
+	require.NoError(t, b.WriteArchive(archive))
+
+	// extract the bundle, then tamper with it
+	f, err := Open(archive)
+	require.NoError(t, err)
+	manifest, err := ReadManifest(f)
+	require.NoError(t, err)
+	extracted := filepath.Join(dir, "extracted")
+	for _, file := range manifest.Files {
+		writeTestFile(t, extracted, file.Path, string(b.Files[file.Path]))
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "extracted", "ls.yaml"))
+	require.NoError(t, err)
+	manifestContent, err := fs2.ReadFile(f, ManifestFileName)
+	require.NoError(t, err)
+	writeTestFile(t, extracted, ManifestFileName, string(manifestContent))
+
+	f, err = Open(extracted)
+	require.NoError(t, err)
+	problems, err := Verify(f)
+	require.NoError(t, err)
+	assert.Empty(t, problems)
+
+	writeTestFile(t, extracted, "ls.yaml", string(content)+"query: DROP TABLE users\n")
+	require.NoError(t, os.Remove(filepath.Join(extracted, "doc/topics/01-ops.md")))
+	writeTestFile(t, extracted, "ops/hosts.yaml", "name: hosts\n")
+
+	problems, err = Verify(f)
+	require.NoError(t, err)
+	files := []string{}
+	for _, p := range problems {
+		files = append(files, p.File+": "+p.Message)
+	}
+	require.Len(t, problems, 3, files)
+	assert.Equal(t, "doc/topics/01-ops.md", problems[0].File)
+	assert.Equal(t, "missing file", problems[0].Message)
+	assert.Equal(t, "ls.yaml", problems[1].File)
+	assert.Contains(t, problems[1].Message, "checksum mismatch")
+	assert.Equal(t, "ops/hosts.yaml", problems[2].File)
+	assert.Equal(t, "file not listed in the manifest", problems[2].Message)
+}
+
+func TestNewBundleResolvesAliasesAcrossDirectories(t *testing.T) {
+	dir1 := t.TempDir()
+	writeTestFile(t, dir1, "ops/users.yaml", "name: users\nshort: List users\n")
+	writeTestFile(t, dir1, "ls.yaml", "name: ls\nshort: List\n")
+	dir2 := t.TempDir()
+	writeTestFile(t, dir2, "ops/users/mine.yaml", "name: mine\naliasFor: users\n")
+	// the same file in both directories
+	writeTestFile(t, dir2, "ls.yaml", "name: ls\nshort: List\n")
+
+	b, err := NewBundle(claycmds.NewRawCommandLoader(), []string{dir1, dir2})
+	require.NoError(t, err)
+
+	paths := []string{}
+	for _, c := range b.Manifest.Commands {
+		paths = append(paths, c.Path+"="+c.Source)
+	}
+	assert.Equal(t, []string{
+		"ls=ls.yaml",
+		"ops users=ops/users.yaml",
+		"ops users mine=ops/users/mine.yaml",
+	}, paths)
+	assert.Len(t, b.Manifest.Files, 3)
+}
+
+func TestNewBundleFailsOnPendingAliases(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "ops/users/mine.yaml", "name: mine\naliasFor: users\n")
+
+	_, err := NewBundle(claycmds.NewRawCommandLoader(), []string{dir})
+	assert.ErrorContains(t, err, "ops users mine")
+}
@@ -0,0 +1,84 @@
+package bundle
+
+import (
+	"fmt"
+	claycmds "github.com/go-go-golems/clay/pkg/cmds"
+	fs2 "io/fs"
+	"sort"
+)
+
+// A Problem is a mismatch between a bundle and its manifest.
+type Problem struct {
+	File    string
+	Message string
+}
+
+// Verify checks the files of a bundle against its manifest.
+//
+// It reports files listed in the manifest that are missing or whose checksum doesn't match,
+// commands whose source file is not listed in the manifest or has a different checksum,
+// and files in the bundle that are not listed in the manifest.
+// An error is only returned if the manifest itself can't be read.
+func Verify(f fs2.FS) ([]Problem, error) {
+	manifest, err := ReadManifest(f)
+	if err != nil {
+		return nil, err
+	}
+
+	problems := []Problem{}
+	listed := map[string]string{}
+	for _, file := range manifest.Files {
+		listed[file.Path] = file.SHA256
+
+		content, err := fs2.ReadFile(f, file.Path)
+		if err != nil {
+			problems = append(problems, Problem{File: file.Path, Message: "missing file"})
+			continue
+		}
+		if hash := claycmds.HashContent(content); hash != file.SHA256 {
+			problems = append(problems, Problem{
+				File:    file.Path,
+				Message: fmt.Sprintf("checksum mismatch: expected %s, got %s", file.SHA256, hash),
+			})
+		}
+	}
+
+	for _, command := range manifest.Commands {
+		hash, ok := listed[command.Source]
+		if !ok {
+			problems = append(problems, Problem{
+				File:    command.Source,
+				Message: fmt.Sprintf("source of command %s is not listed in the manifest", command.Path),
+			})
+			continue
+		}
+		if hash != command.SHA256 {
+			problems = append(problems, Problem{
+				File:    command.Source,
+				Message: fmt.Sprintf("checksum of command %s doesn't match its source file", command.Path),
+			})
+		}
+	}
+
+	err = fs2.WalkDir(f, ".", func(path string, d fs2.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path == ManifestFileName {
+			return nil
+		}
+		if _, ok := listed[path]; !ok {
+			problems = append(problems, Problem{File: path, Message: "file not listed in the manifest"})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].File < problems[j].File
+	})
+
+	return problems, nil
+}
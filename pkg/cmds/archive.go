@@ -0,0 +1,95 @@
+package cmds
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"github.com/pkg/errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// IsArchive returns true if fileName is a command bundle that can be opened with OpenArchive,
+// that is a .zip, .tar.gz or .tgz file.
+func IsArchive(fileName string) bool {
+	lower := strings.ToLower(fileName)
+	return strings.HasSuffix(lower, ".zip") ||
+		strings.HasSuffix(lower, ".tar.gz") ||
+		strings.HasSuffix(lower, ".tgz")
+}
+
+// OpenArchive opens a .zip, .tar.gz or .tgz command bundle as an fs.FS.
+//
+// The archive is read into memory, so the returned FS doesn't need to be closed.
+// The root of the archive is the root of the command repository, and can contain
+// a doc/ directory with help sections, just like a repository directory.
+func OpenArchive(fileName string) (fs.FS, error) {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	lower := strings.ToLower(fileName)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		r, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not open zip archive %s", fileName)
+		}
+		return r, nil
+
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		ret, err := readTarGz(bytes.NewReader(content))
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not open tar.gz archive %s", fileName)
+		}
+		return ret, nil
+
+	default:
+		return nil, errors.Errorf("unsupported archive %s", fileName)
+	}
+}
+
+func readTarGz(r io.Reader) (*MemoryFS, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer func(gz *gzip.Reader) {
+		_ = gz.Close()
+	}(gz)
+
+	ret := NewMemoryFS()
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if name == "." || !fs.ValidPath(name) {
+			continue
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			ret.AddDir(name, header.ModTime)
+		case tar.TypeReg:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			ret.AddFile(name, data, header.ModTime)
+		}
+	}
+
+	return ret, nil
+}
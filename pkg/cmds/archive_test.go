@@ -0,0 +1,105 @@
+package cmds
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	glazed_cmds "github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/help"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var testArchiveFiles = map[string]string{
+	"ops/users.yaml": "name: users\nshort: List users\n",
+	"ls.yaml":        "name: ls\nshort: List\n",
+	"doc/topics/01-ops.md": `---
+Title: Ops commands
+Slug: ops-commands
+Short: How to use the ops commands
+SectionType: GeneralTopic
+---
+
+Use the ops commands.
+`,
+}
+
+func writeZip(t *testing.T, fileName string) {
+	f, err := os.Create(fileName)
+	require.NoError(t, err)
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	for name, content := range testArchiveFiles {
+		fw, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = fw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+}
+
+func writeTarGz(t *testing.T, fileName string) {
+	f, err := os.Create(fileName)
+	require.NoError(t, err)
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	w := tar.NewWriter(gz)
+	for name, content := range testArchiveFiles {
+		err = w.WriteHeader(&tar.Header{
+			Name:     "./" + name,
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			Size:     int64(len(content)),
+		})
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	require.NoError(t, gz.Close())
+}
+
+func TestLoadRepositoryArchives(t *testing.T) {
+	dir := t.TempDir()
+	archives := map[string]func(*testing.T, string){
+		"pack.zip":    writeZip,
+		"pack.tar.gz": writeTarGz,
+	}
+
+	for name, write := range archives {
+		t.Run(name, func(t *testing.T) {
+			archive := filepath.Join(dir, name)
+			write(t, archive)
+
+			locations := NewCommandLocations(WithRepositories(archive))
+			helpSystem := help.NewHelpSystem()
+			commands, aliases, err := NewCommandLoader[glazed_cmds.Command](locations).
+				LoadCommands(NewRawCommandLoader(), helpSystem)
+			require.NoError(t, err)
+			assert.Empty(t, aliases)
+
+			paths := []string{}
+			for _, c := range commands {
+				d := c.Description()
+				paths = append(paths, strings.Join(append(append([]string{}, d.Parents...), d.Name), " ")+
+					" "+d.Source)
+			}
+			sort.Strings(paths)
+			assert.Equal(t, []string{
+				"ls " + archive + "/ls.yaml",
+				"ops users " + archive + "/ops/users.yaml",
+			}, paths)
+
+			section, err := helpSystem.GetSectionWithSlug("ops-commands")
+			require.NoError(t, err)
+			assert.Equal(t, "Ops commands", section.Title)
+		})
+	}
+}
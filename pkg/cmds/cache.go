@@ -0,0 +1,264 @@
+package cmds
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	glazed_cmds "github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/go-go-golems/glazed/pkg/cmds/loaders"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync"
+)
+
+// The command cache stores the commands parsed from a repository directory on disk,
+// so that applications with large repositories don't have to parse every file on startup.
+//
+// Each repository directory gets its own index file in the cache directory, which maps
+// every file to its modification time, size, and serialized commands. Only the files
+// whose modification time or size changed are parsed again. The whole index is discarded
+// if the version of clay, the version of the application, or the loader type changes.
+//
+// Only loaders implementing SerializableCommandLoader can be cached.
+
+// SerializableCommandLoader is a CommandLoader whose commands can be stored in the command cache.
+type SerializableCommandLoader interface {
+	loaders.CommandLoader
+	// SerializeCommands serializes the commands loaded from a single file, without any
+	// description or alias options applied. It returns ErrNotCacheable if the commands
+	// can't be serialized, in which case the file is parsed on every startup.
+	SerializeCommands(commands []glazed_cmds.Command) ([]byte, error)
+	// DeserializeCommands restores the commands serialized by SerializeCommands,
+	// applying the given options as LoadCommands would.
+	DeserializeCommands(
+		data []byte,
+		options []glazed_cmds.CommandDescriptionOption,
+		aliasOptions []alias.Option,
+	) ([]glazed_cmds.Command, error)
+}
+
+var ErrNotCacheable = errors.New("commands can't be cached")
+
+// CommandCache stores the parsed commands of repository directories in Directory.
+type CommandCache struct {
+	Directory string
+	// Version is the version of the application, changing it invalidates the cache.
+	Version string
+}
+
+// NewCommandCache creates a cache storing its index files in directory.
+func NewCommandCache(directory string, version string) *CommandCache {
+	return &CommandCache{
+		Directory: directory,
+		Version:   version,
+	}
+}
+
+// NewDefaultCommandCache creates a cache in the commands directory of the user cache
+// directory of the application, for example ~/.cache/sqleton/commands.
+func NewDefaultCommandCache(appName string, version string) (*CommandCache, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewCommandCache(filepath.Join(cacheDir, appName, "commands"), version), nil
+}
+
+type commandCacheEntry struct {
+	ModTime int64           `json:"modTime"`
+	Size    int64           `json:"size"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	// NotCacheable records that the commands of the file can't be serialized,
+	// so that the file is parsed only once with the options applied
+	NotCacheable bool `json:"notCacheable,omitempty"`
+}
+
+type commandCacheIndex struct {
+	ClayVersion string                        `json:"clayVersion"`
+	Version     string                        `json:"version"`
+	LoaderType  string                        `json:"loaderType"`
+	Entries     map[string]*commandCacheEntry `json:"entries"`
+}
+
+// clayVersion returns the version of the clay module the binary was built with.
+func clayVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	const clayModule = "github.com/go-go-golems/clay"
+	if info.Main.Path == clayModule {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == clayModule {
+			if dep.Replace != nil {
+				return dep.Replace.Path + "@" + dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return ""
+}
+
+func (c *CommandCache) indexPath(repository string) string {
+	h := sha256.Sum256([]byte(repository))
+	return filepath.Join(c.Directory, hex.EncodeToString(h[:8])+".json")
+}
+
+// cachingLoader loads commands from the cache index of a single repository,
+// and records the files it had to parse. It is safe for concurrent use if the
+// wrapped loader is.
+type cachingLoader struct {
+	SerializableCommandLoader
+	mu    sync.Mutex
+	index *commandCacheIndex
+	// entries are the entries of the files loaded during this run, stale entries are dropped on save
+	entries map[string]*commandCacheEntry
+	dirty   bool
+}
+
+// openRepository returns a loader that reads the commands of the repository directory from the cache.
+// The cache index is rewritten by save, if any file was parsed.
+func (c *CommandCache) openRepository(repository string, loader SerializableCommandLoader) *cachingLoader {
+	ret := &cachingLoader{
+		SerializableCommandLoader: loader,
+		index: &commandCacheIndex{
+			ClayVersion: clayVersion(),
+			Version:     c.Version,
+			LoaderType:  fmt.Sprintf("%T", loader),
+			Entries:     map[string]*commandCacheEntry{},
+		},
+		entries: map[string]*commandCacheEntry{},
+	}
+
+	path := c.indexPath(repository)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Debug().Err(err).Str("index", path).Msg("Could not read command cache")
+		}
+		return ret
+	}
+	index := &commandCacheIndex{}
+	err = json.Unmarshal(data, index)
+	if err != nil {
+		log.Debug().Err(err).Str("index", path).Msg("Could not parse command cache, ignoring it")
+		return ret
+	}
+	if index.ClayVersion != ret.index.ClayVersion ||
+		index.Version != ret.index.Version ||
+		index.LoaderType != ret.index.LoaderType {
+		log.Debug().Str("index", path).Msg("Command cache is outdated, ignoring it")
+		return ret
+	}
+	if index.Entries != nil {
+		ret.index.Entries = index.Entries
+	}
+
+	return ret
+}
+
+func (c *cachingLoader) LoadCommands(
+	f fs.FS, entryName string,
+	options []glazed_cmds.CommandDescriptionOption,
+	aliasOptions []alias.Option,
+) ([]glazed_cmds.Command, error) {
+	s, err := fs.Stat(f, entryName)
+	if err != nil {
+		return nil, err
+	}
+	modTime, size := s.ModTime().UnixNano(), s.Size()
+
+	c.mu.Lock()
+	entry, ok := c.index.Entries[entryName]
+	c.mu.Unlock()
+	if ok && entry.ModTime == modTime && entry.Size == size && entry.NotCacheable {
+		c.mu.Lock()
+		c.entries[entryName] = entry
+		c.mu.Unlock()
+		return c.SerializableCommandLoader.LoadCommands(f, entryName, options, aliasOptions)
+	}
+	if ok && entry.ModTime == modTime && entry.Size == size {
+		commands, err := c.DeserializeCommands(entry.Data, options, aliasOptions)
+		if err == nil {
+			c.mu.Lock()
+			c.entries[entryName] = entry
+			c.mu.Unlock()
+			return commands, nil
+		}
+		log.Debug().Err(err).Str("file", entryName).Msg("Could not restore cached commands")
+	}
+
+	commands, err := c.SerializableCommandLoader.LoadCommands(f, entryName, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.SerializeCommands(commands)
+	if err != nil {
+		if !errors.Is(err, ErrNotCacheable) {
+			return nil, err
+		}
+		// parse the file again with the options applied, and remember not to try
+		// serializing it until it changes
+		c.mu.Lock()
+		c.entries[entryName] = &commandCacheEntry{
+			ModTime:      modTime,
+			Size:         size,
+			NotCacheable: true,
+		}
+		c.dirty = true
+		c.mu.Unlock()
+		return c.SerializableCommandLoader.LoadCommands(f, entryName, options, aliasOptions)
+	}
+
+	c.mu.Lock()
+	c.entries[entryName] = &commandCacheEntry{
+		ModTime: modTime,
+		Size:    size,
+		Data:    data,
+	}
+	c.dirty = true
+	c.mu.Unlock()
+
+	return c.DeserializeCommands(data, options, aliasOptions)
+}
+
+// save writes the index of the files loaded during this run to the cache.
+func (c *CommandCache) save(repository string, loader *cachingLoader) error {
+	if !loader.dirty && len(loader.entries) == len(loader.index.Entries) {
+		return nil
+	}
+	loader.index.Entries = loader.entries
+
+	data, err := json.Marshal(loader.index)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(c.Directory, 0755)
+	if err != nil {
+		return err
+	}
+
+	// write atomically, so that concurrent runs never read a partial index
+	path := c.indexPath(repository)
+	tmp, err := os.CreateTemp(c.Directory, filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
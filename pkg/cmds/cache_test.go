@@ -0,0 +1,116 @@
+package cmds
+
+import (
+	glazed_cmds "github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/go-go-golems/glazed/pkg/help"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// countingLoader counts the files that are actually parsed.
+type countingLoader struct {
+	RawCommandLoader
+	parsed []string
+}
+
+func (c *countingLoader) LoadCommands(
+	f fs.FS, entryName string,
+	options []glazed_cmds.CommandDescriptionOption,
+	aliasOptions []alias.Option,
+) ([]glazed_cmds.Command, error) {
+	c.parsed = append(c.parsed, entryName)
+	return c.RawCommandLoader.LoadCommands(f, entryName, options, aliasOptions)
+}
+
+func writeCacheTestFile(t *testing.T, dir string, name string, content string) {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func loadCached(t *testing.T, dir string, cache *CommandCache) ([]glazed_cmds.Command, []*alias.CommandAlias, []string) {
+	loader := &countingLoader{}
+	locations := NewCommandLocations(WithRepositories(dir), WithCommandCache(cache))
+	commands, aliases, err := NewCommandLoader[glazed_cmds.Command](locations).
+		LoadCommands(loader, help.NewHelpSystem())
+	require.NoError(t, err)
+	return commands, aliases, loader.parsed
+}
+
+func TestCommandCache(t *testing.T) {
+	dir := t.TempDir()
+	cache := NewCommandCache(t.TempDir(), "1.0.0")
+	writeCacheTestFile(t, dir, "ops/users.yaml", "name: users\nshort: List users\nflags:\n  - name: limit\n    type: int\n    default: 10\n")
+	writeCacheTestFile(t, dir, "ops/users/active.yaml", "name: active\naliasFor: users\nflags:\n  active: \"true\"\n")
+	writeCacheTestFile(t, dir, "ls.yaml", "name: ls\nshort: List\n")
+
+	commands, aliases, parsed := loadCached(t, dir, cache)
+	assert.ElementsMatch(t, []string{"ls.yaml", "ops/users.yaml", "ops/users/active.yaml"}, parsed)
+	require.Len(t, commands, 2)
+	require.Len(t, aliases, 1)
+
+	// the second run restores all commands from the cache
+	cachedCommands, cachedAliases, parsed := loadCached(t, dir, cache)
+	assert.Empty(t, parsed)
+	assert.Equal(t, commands, cachedCommands)
+	assert.Equal(t, aliases, cachedAliases)
+	users := cachedCommands[1].(*RawCommand)
+	assert.Equal(t, []string{"ops"}, users.Parents)
+	assert.Equal(t, dir+"/ops/users.yaml", users.Source)
+	assert.Equal(t, 10, users.YAMLContent["flags"].([]interface{})[0].(map[string]interface{})["default"])
+
+	// only changed files are parsed again
+	writeCacheTestFile(t, dir, "ls.yaml", "name: ls\nshort: List files\n")
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "ls.yaml"), future, future))
+	require.NoError(t, os.Remove(filepath.Join(dir, "ops/users/active.yaml")))
+	commands, aliases, parsed = loadCached(t, dir, cache)
+	assert.Equal(t, []string{"ls.yaml"}, parsed)
+	assert.Equal(t, "List files", commands[0].Description().Short)
+	assert.Empty(t, aliases)
+
+	// a new version invalidates the cache
+	_, _, parsed = loadCached(t, dir, NewCommandCache(cache.Directory, "1.0.1"))
+	assert.ElementsMatch(t, []string{"ls.yaml", "ops/users.yaml"}, parsed)
+}
+
+// uncacheableLoader is a countingLoader whose commands can't be serialized.
+type uncacheableLoader struct {
+	countingLoader
+}
+
+func (u *uncacheableLoader) SerializeCommands(commands []glazed_cmds.Command) ([]byte, error) {
+	return nil, ErrNotCacheable
+}
+
+func TestCommandCacheRecordsUncacheableFiles(t *testing.T) {
+	dir := t.TempDir()
+	cache := NewCommandCache(t.TempDir(), "1.0.0")
+	writeCacheTestFile(t, dir, "ls.yaml", "name: ls\nshort: List\n")
+
+	load := func() ([]glazed_cmds.Command, []string) {
+		loader := &uncacheableLoader{}
+		locations := NewCommandLocations(WithRepositories(dir), WithCommandCache(cache))
+		commands, _, err := NewCommandLoader[glazed_cmds.Command](locations).
+			LoadCommands(loader, help.NewHelpSystem())
+		require.NoError(t, err)
+		return commands, loader.parsed
+	}
+
+	// the first run finds out that the file can't be cached
+	commands, parsed := load()
+	assert.Equal(t, []string{"ls.yaml", "ls.yaml"}, parsed)
+	require.Len(t, commands, 1)
+
+	// later runs parse it only once
+	commands, parsed = load()
+	assert.Equal(t, []string{"ls.yaml"}, parsed)
+	require.Len(t, commands, 1)
+	assert.Equal(t, filepath.Join(dir, "ls.yaml"), commands[0].Description().Source)
+}
@@ -0,0 +1,44 @@
+package cmds
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// LoadError records a file that could not be loaded.
+type LoadError struct {
+	// File is the file that failed to load, prefixed like the Source of the commands
+	// of its location (for example the repository directory, or "embed:name:").
+	File string
+	// Line is the line of the error in File, or 0 if it is not known.
+	Line int
+	Err  error
+}
+
+func (e *LoadError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.File, e.Err)
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// yamlLineRegexp matches the line number in the errors returned by the YAML parser,
+// for example "yaml: line 3: did not find expected key".
+var yamlLineRegexp = regexp.MustCompile(`\bline (\d+)\b`)
+
+// NewLoadError creates a LoadError, extracting the line number from YAML errors.
+func NewLoadError(file string, err error) *LoadError {
+	ret := &LoadError{
+		File: file,
+		Err:  err,
+	}
+	if m := yamlLineRegexp.FindStringSubmatch(err.Error()); m != nil {
+		ret.Line, _ = strconv.Atoi(m[1])
+	}
+	return ret
+}
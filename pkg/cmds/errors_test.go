@@ -0,0 +1,62 @@
+package cmds
+
+import (
+	glazed_cmds "github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/help"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"testing/fstest"
+)
+
+func TestNewLoadErrorExtractsLine(t *testing.T) {
+	e := NewLoadError("ops/users.yaml", errors.New("yaml: line 3: did not find expected key"))
+	assert.Equal(t, 3, e.Line)
+	assert.Equal(t, "ops/users.yaml:3: yaml: line 3: did not find expected key", e.Error())
+
+	e = NewLoadError("ops/users.yaml", errors.New("file is an alias"))
+	assert.Equal(t, 0, e.Line)
+	assert.Equal(t, "ops/users.yaml: file is an alias", e.Error())
+}
+
+func TestLenientCommandLoader(t *testing.T) {
+	good := EmbeddedCommandLocation{
+		FS: fstest.MapFS{
+			"queries/ls.yaml":     {Data: []byte("name: ls\n")},
+			"queries/broken.yaml": {Data: []byte("name: broken\nshort: [\n")},
+		},
+		Name: "good",
+		Root: "queries",
+	}
+	// the root of this location doesn't exist
+	missing := EmbeddedCommandLocation{
+		FS:   fstest.MapFS{},
+		Name: "missing",
+		Root: "queries",
+	}
+
+	dir := t.TempDir()
+	writeCacheTestFile(t, dir, "ops/users.yaml", "name: users\n")
+
+	locations := NewCommandLocations(WithEmbeddedLocations(good, missing), WithRepositories(dir))
+	commandLoader := NewCommandLoader[glazed_cmds.Command](locations)
+	_, _, err := commandLoader.LoadCommands(NewRawCommandLoader(), help.NewHelpSystem())
+	require.Error(t, err)
+
+	locations = NewCommandLocations(
+		WithEmbeddedLocations(good, missing),
+		WithRepositories(dir),
+		WithLenient(true),
+	)
+	commandLoader = NewCommandLoader[glazed_cmds.Command](locations)
+	commands, _, err := commandLoader.LoadCommands(NewRawCommandLoader(), help.NewHelpSystem())
+	require.NoError(t, err)
+	assert.Len(t, commands, 2)
+
+	loadErrors := commandLoader.LoadErrors()
+	require.Len(t, loadErrors, 2)
+	assert.Equal(t, "embed:good:queries/broken.yaml", loadErrors[0].File)
+	assert.Equal(t, 2, loadErrors[0].Line)
+	assert.Equal(t, "embed:missing:queries", loadErrors[1].File)
+}
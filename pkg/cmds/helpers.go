@@ -1,15 +1,81 @@
 package cmds
 
 import (
+	"bytes"
 	"context"
 	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
 	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
 	"github.com/go-go-golems/glazed/pkg/middlewares"
 	"github.com/go-go-golems/glazed/pkg/types"
+	"sort"
+	"strings"
 )
 
+// CommandPath returns the full path of a command or alias, its parents followed by its name.
+func CommandPath(command cmds.Command) []string {
+	if alias_, ok := command.(*alias.CommandAlias); ok {
+		return append(append([]string{}, alias_.Parents...), alias_.Name)
+	}
+	description := command.Description()
+	return append(append([]string{}, description.Parents...), description.Name)
+}
+
+// CommandSource returns the source of a command or alias, usually the file it was loaded from.
+func CommandSource(command cmds.Command) string {
+	if alias_, ok := command.(*alias.CommandAlias); ok {
+		return alias_.Source
+	}
+	return command.Description().Source
+}
+
+// GetAliasChain returns the names of alias_, of the aliases it extends, and of the command
+// it finally aliases, in that order. The chain stops at the first unresolved alias.
+func GetAliasChain(alias_ *alias.CommandAlias) []string {
+	ret := []string{}
+	visited := map[*alias.CommandAlias]bool{}
+	var current cmds.Command = alias_
+	for current != nil {
+		a, ok := current.(*alias.CommandAlias)
+		if !ok {
+			ret = append(ret, current.Description().Name)
+			break
+		}
+		if visited[a] {
+			break
+		}
+		visited[a] = true
+		ret = append(ret, a.Name)
+		current = a.AliasedCommand
+	}
+	return ret
+}
+
 func ListCommandsIntoProcessor(ctx context.Context, commands []cmds.Command, gp middlewares.Processor) error {
 	for _, cmd := range commands {
+		if alias_, ok := cmd.(*alias.CommandAlias); ok {
+			flags := []string{}
+			for k, v := range alias_.Flags {
+				flags = append(flags, k+"="+v)
+			}
+			sort.Strings(flags)
+			row := types.NewRow(
+				types.MRP("command", alias_.Name),
+				types.MRP("type", "alias"),
+				types.MRP("alias_chain", strings.Join(GetAliasChain(alias_), " -> ")),
+				types.MRP("resolved", alias_.AliasedCommand != nil),
+				types.MRP("flags", strings.Join(flags, " ")),
+			)
+			err := gp.AddRow(ctx, row)
+			if err != nil {
+				return err
+			}
+			// unresolved aliases don't have a description
+			if alias_.AliasedCommand == nil {
+				continue
+			}
+		}
+
 		description := cmd.Description()
 		err := description.GetDefaultFlags().ForEachE(func(flag *parameters.ParameterDefinition) error {
 			row := types.NewRow(types.MRP("command", description.Name), types.MRP("type", "flag"))
@@ -39,3 +105,30 @@ func ListCommandsIntoProcessor(ctx context.Context, commands []cmds.Command, gp
 	}
 	return nil
 }
+
+// CommandToYAML serializes a command or alias back to the YAML it can be loaded from.
+//
+// RawCommands return their original file content, aliases are stripped of their
+// resolved command and of the fields that are derived from their location on disk
+// (parents and source), so that the result can be written back into a repository.
+func CommandToYAML(command cmds.Command) ([]byte, error) {
+	switch c := command.(type) {
+	case *RawCommand:
+		if len(c.Content) > 0 {
+			return c.Content, nil
+		}
+	case *alias.CommandAlias:
+		a := *c
+		a.AliasedCommand = nil
+		a.Parents = nil
+		a.Source = ""
+		command = &a
+	}
+
+	buf := &bytes.Buffer{}
+	err := command.ToYAML(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,119 @@
+package cmds
+
+import (
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/pkg/errors"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFileName is the name of the file listing the files of a repository that should not be
+// loaded, using the gitignore syntax. It is read from the root of every repository, and its
+// patterns are relative to that root.
+const IgnoreFileName = ".clayignore"
+
+type ignorePattern struct {
+	pattern string
+	negate  bool
+	// dirOnly patterns end with a slash and only match directories
+	dirOnly bool
+	// anchored patterns contain a slash and are matched against the whole path,
+	// the others against the name of the file or directory
+	anchored bool
+}
+
+// IgnoreMatcher matches the paths of a repository against gitignore patterns.
+// A nil IgnoreMatcher doesn't ignore anything.
+type IgnoreMatcher struct {
+	patterns []ignorePattern
+}
+
+// NewIgnoreMatcher creates a matcher from gitignore lines.
+// Empty lines and comments are skipped, and later patterns take precedence.
+func NewIgnoreMatcher(lines ...string) *IgnoreMatcher {
+	ret := &IgnoreMatcher{}
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p := ignorePattern{}
+		if strings.HasPrefix(line, "!") {
+			p.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			// escaped leading ! or #
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			p.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		p.pattern = line
+		ret.patterns = append(ret.patterns, p)
+	}
+	return ret
+}
+
+// LoadIgnoreMatcher creates a matcher from the IgnoreFileName in dir, if it exists,
+// followed by the exclude patterns.
+func LoadIgnoreMatcher(f fs.FS, dir string, exclude []string) (*IgnoreMatcher, error) {
+	lines := []string{}
+	content, err := fs.ReadFile(f, path.Join(filepath.ToSlash(dir), IgnoreFileName))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, errors.Wrapf(err, "could not read %s", IgnoreFileName)
+	}
+	if err == nil {
+		lines = append(lines, strings.Split(string(content), "\n")...)
+	}
+	// exclude patterns come last, so that they can't be negated by the ignore file
+	lines = append(lines, exclude...)
+	return NewIgnoreMatcher(lines...), nil
+}
+
+// Match returns true if the file or directory at path, relative to the root of the repository,
+// is ignored. Files inside an ignored directory are ignored as well.
+func (m *IgnoreMatcher) Match(path_ string, isDir bool) bool {
+	if m == nil || len(m.patterns) == 0 {
+		return false
+	}
+	path_ = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(path_)), "./")
+	if path_ == "." || path_ == "" {
+		return false
+	}
+
+	parts := strings.Split(path_, "/")
+	for i := range parts {
+		if m.matches(parts[:i+1], i < len(parts)-1 || isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *IgnoreMatcher) matches(parts []string, isDir bool) bool {
+	ignored := false
+	for _, p := range m.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		target := parts[len(parts)-1]
+		if p.anchored {
+			target = strings.Join(parts, "/")
+		}
+		if ok, _ := doublestar.Match(p.pattern, target); ok {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
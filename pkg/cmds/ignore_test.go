@@ -0,0 +1,92 @@
+package cmds
+
+import (
+	glazed_cmds "github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/help"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sort"
+	"testing"
+	"testing/fstest"
+)
+
+func TestIgnoreMatcher(t *testing.T) {
+	m := NewIgnoreMatcher(
+		"# drafts and fixtures",
+		"",
+		"*.draft.yaml",
+		"fixtures/",
+		"/testdata",
+		"ops/legacy/*.yaml",
+		"!ops/legacy/keep.yaml",
+	)
+
+	tests := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"users.yaml", false, false},
+		{"users.draft.yaml", false, true},
+		{"ops/users.draft.yaml", false, true},
+		{"fixtures", true, true},
+		{"fixtures", false, false},
+		{"ops/fixtures/users.yaml", false, true},
+		{"testdata/users.yaml", false, true},
+		{"ops/testdata/users.yaml", false, false},
+		{"ops/legacy/users.yaml", false, true},
+		{"ops/legacy/keep.yaml", false, false},
+		{"./users.draft.yaml", false, true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.ignored, m.Match(tt.path, tt.isDir), tt.path)
+	}
+
+	var nilMatcher *IgnoreMatcher
+	assert.False(t, nilMatcher.Match("users.yaml", false))
+}
+
+func TestLoadCommandsFromFSSkipsIgnoredFiles(t *testing.T) {
+	f := fstest.MapFS{
+		"queries/.clayignore":            {Data: []byte("drafts/\n*.wip.yaml\n")},
+		"queries/users.yaml":             {Data: []byte("name: users\n")},
+		"queries/users.wip.yaml":         {Data: []byte("name: wip\n")},
+		"queries/drafts/broken.yaml":     {Data: []byte("name: [\n")},
+		"queries/ops/hosts.yaml":         {Data: []byte("name: hosts\n")},
+		"queries/ops/fixtures/data.yaml": {Data: []byte("name: data\n")},
+	}
+
+	commands, err := LoadCommandsFromFS(f, "queries", NewRawCommandLoader(), nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"data", "hosts", "users"}, getSortedCommandNames(commands))
+
+	commands, err = LoadCommandsFromFSWithExclude(f, "queries", NewRawCommandLoader(), nil, nil, []string{"fixtures/"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hosts", "users"}, getSortedCommandNames(commands))
+}
+
+func TestCommandLocationsExclude(t *testing.T) {
+	dir := t.TempDir()
+	writeCacheTestFile(t, dir, ".clayignore", "drafts/\n")
+	writeCacheTestFile(t, dir, "users.yaml", "name: users\n")
+	writeCacheTestFile(t, dir, "drafts/users.yaml", "name: draft\n")
+	writeCacheTestFile(t, dir, "ops/hosts.yaml", "name: hosts\n")
+
+	loader := NewCommandLoader[glazed_cmds.Command](NewCommandLocations(
+		WithRepositories(dir),
+		WithExclude("ops/hosts.yaml"),
+	))
+	commands, _, err := loader.LoadCommands(NewRawCommandLoader(), help.NewHelpSystem())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"users"}, getSortedCommandNames(commands))
+	assert.Empty(t, loader.LoadErrors())
+}
+
+func getSortedCommandNames(commands []glazed_cmds.Command) []string {
+	ret := []string{}
+	for _, c := range commands {
+		ret = append(ret, c.Description().Name)
+	}
+	sort.Strings(ret)
+	return ret
+}
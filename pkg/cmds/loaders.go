@@ -0,0 +1,236 @@
+package cmds
+
+import (
+	map_pool "github.com/go-go-golems/clay/pkg/workerpool/map-pool"
+	glazed_cmds "github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/go-go-golems/glazed/pkg/cmds/loaders"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// LoadCommandsFromFS walks the FS and loads all commands and command aliases found.
+// The files matched by the IgnoreFileName in dir are skipped.
+//
+// This mirrors loaders.LoadCommandsFromFS in glazed, but also records the file
+// an alias was loaded from in its Source, which glazed only does for commands.
+// This allows repositories to know which file defined which alias.
+func LoadCommandsFromFS(
+	f fs.FS, dir string,
+	loader loaders.CommandLoader,
+	options []glazed_cmds.CommandDescriptionOption,
+	aliasOptions []alias.Option,
+) ([]glazed_cmds.Command, error) {
+	return LoadCommandsFromFSWithWorkers(f, dir, loader, options, aliasOptions, 1)
+}
+
+// LoadCommandsFromFSWithWorkers is LoadCommandsFromFS, parsing up to workerCount files
+// concurrently. The loader must be safe for concurrent use if workerCount is larger than 1.
+//
+// The commands are returned in the same order as with LoadCommandsFromFS, so that
+// inserting them into a repository gives the same result.
+func LoadCommandsFromFSWithWorkers(
+	f fs.FS, dir string,
+	loader loaders.CommandLoader,
+	options []glazed_cmds.CommandDescriptionOption,
+	aliasOptions []alias.Option,
+	workerCount int,
+) ([]glazed_cmds.Command, error) {
+	return loadCommandsAndLogErrors(f, dir, loader, options, aliasOptions, workerCount, nil)
+}
+
+// LoadCommandsFromFSWithExclude is LoadCommandsFromFS, also skipping the files that match
+// the exclude patterns, in gitignore syntax relative to dir.
+func LoadCommandsFromFSWithExclude(
+	f fs.FS, dir string,
+	loader loaders.CommandLoader,
+	options []glazed_cmds.CommandDescriptionOption,
+	aliasOptions []alias.Option,
+	exclude []string,
+) ([]glazed_cmds.Command, error) {
+	return loadCommandsAndLogErrors(f, dir, loader, options, aliasOptions, 1, exclude)
+}
+
+func loadCommandsAndLogErrors(
+	f fs.FS, dir string,
+	loader loaders.CommandLoader,
+	options []glazed_cmds.CommandDescriptionOption,
+	aliasOptions []alias.Option,
+	workerCount int,
+	exclude []string,
+) ([]glazed_cmds.Command, error) {
+	commands, loadErrors, err := loadCommandsFromFS(f, dir, loader, options, aliasOptions, workerCount, exclude)
+	if err != nil {
+		return nil, err
+	}
+	for _, loadError := range loadErrors {
+		log.Warn().Err(loadError.Err).Str("file", loadError.File).Msg("Could not load command from file")
+	}
+	return commands, nil
+}
+
+// loadCommandsFromFS loads the commands below dir, returning the files that couldn't be loaded
+// separately. The files matched by the IgnoreFileName in dir or by the exclude patterns are skipped.
+// The returned error is only set if dir can't be walked.
+func loadCommandsFromFS(
+	f fs.FS, dir string,
+	loader loaders.CommandLoader,
+	options []glazed_cmds.CommandDescriptionOption,
+	aliasOptions []alias.Option,
+	workerCount int,
+	exclude []string,
+) ([]glazed_cmds.Command, []*LoadError, error) {
+	ignore, err := LoadIgnoreMatcher(f, dir, exclude)
+	if err != nil {
+		return nil, nil, err
+	}
+	files, err := listCommandFiles(f, dir, loader, ignore)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results := make([]fileCommands, len(files))
+	if workerCount <= 1 || len(files) <= 1 {
+		for i, fileName := range files {
+			results[i].commands, results[i].err = LoadCommandsFromFile(f, fileName, loader, options, aliasOptions)
+		}
+	} else {
+		loadFilesConcurrently(f, files, loader, options, aliasOptions, workerCount, results)
+	}
+
+	var commands []glazed_cmds.Command
+	loadErrors := []*LoadError{}
+	for i, result := range results {
+		if result.err != nil {
+			loadErrors = append(loadErrors, NewLoadError(files[i], result.err))
+			continue
+		}
+		commands = append(commands, result.commands...)
+	}
+
+	return commands, loadErrors, nil
+}
+
+type fileCommands struct {
+	index    int
+	commands []glazed_cmds.Command
+	err      error
+}
+
+func loadFilesConcurrently(
+	f fs.FS, files []string,
+	loader loaders.CommandLoader,
+	options []glazed_cmds.CommandDescriptionOption,
+	aliasOptions []alias.Option,
+	workerCount int,
+	results []fileCommands,
+) {
+	pool := map_pool.New[fileCommands](workerCount)
+	pool.Start()
+
+	go func() {
+		for i, fileName := range files {
+			i, fileName := i, fileName
+			pool.AddJob(func() (fileCommands, error) {
+				commands, err := LoadCommandsFromFile(f, fileName, loader, options, aliasOptions)
+				// errors are returned as part of the result, the pool drops failed jobs
+				return fileCommands{index: i, commands: commands, err: err}, nil
+			})
+		}
+		pool.Close()
+	}()
+
+	// store the results by file index, to keep the order of the sequential walk
+	for result := range pool.Results() {
+		results[result.index] = result
+	}
+}
+
+// GroupFileName is the name of the file describing the command group of its directory.
+// Group files are not commands, and are skipped when loading commands.
+const GroupFileName = "_group.yaml"
+
+// IsGroupFile returns true if fileName is a group metadata file.
+func IsGroupFile(fileName string) bool {
+	return filepath.Base(fileName) == GroupFileName
+}
+
+// listCommandFiles returns the files below dir that loader supports, in walk order.
+// Hidden files, directories, group files and the paths matched by ignore are skipped.
+func listCommandFiles(f fs.FS, dir string, loader loaders.CommandLoader, ignore *IgnoreMatcher) ([]string, error) {
+	return listCommandFilesBelow(f, dir, dir, loader, ignore)
+}
+
+func listCommandFilesBelow(
+	f fs.FS, root string, dir string,
+	loader loaders.CommandLoader,
+	ignore *IgnoreMatcher,
+) ([]string, error) {
+	files := []string{}
+
+	entries, err := fs.ReadDir(f, dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		// skip hidden files
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		fileName := filepath.Join(dir, entry.Name())
+		if relPath, err := filepath.Rel(root, fileName); err == nil && ignore.Match(relPath, entry.IsDir()) {
+			log.Debug().Str("file", fileName).Msg("Skipping ignored file")
+			continue
+		}
+
+		if entry.IsDir() {
+			subFiles, err := listCommandFilesBelow(f, root, fileName, loader, ignore)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, subFiles...)
+			continue
+		}
+
+		if IsGroupFile(fileName) || !loader.IsFileSupported(f, fileName) {
+			continue
+		}
+		files = append(files, fileName)
+	}
+
+	return files, nil
+}
+
+// LoadCommandsFromFile loads the single command or alias defined in fileName.
+// The parents of the command are derived from the directory of the file.
+func LoadCommandsFromFile(
+	f fs.FS, fileName string,
+	loader loaders.CommandLoader,
+	options []glazed_cmds.CommandDescriptionOption,
+	aliasOptions []alias.Option,
+) ([]glazed_cmds.Command, error) {
+	fromDir := loaders.GetParentsFromDir(filepath.Dir(fileName))
+
+	log.Debug().Str("file", fileName).Msg("Loading command from file")
+	options_ := append([]glazed_cmds.CommandDescriptionOption{
+		glazed_cmds.WithSource(fileName),
+		glazed_cmds.WithParents(fromDir...),
+	}, options...)
+	aliasOptions_ := append([]alias.Option{
+		alias.WithSource(fileName),
+		alias.WithParents(fromDir...),
+	}, aliasOptions...)
+
+	commands, err := loader.LoadCommands(f, fileName, options_, aliasOptions_)
+	if err != nil {
+		return nil, err
+	}
+	if len(commands) != 1 {
+		return nil, errors.New("Expected exactly one command")
+	}
+
+	return commands, nil
+}
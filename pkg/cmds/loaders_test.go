@@ -0,0 +1,132 @@
+package cmds
+
+import (
+	"fmt"
+	glazed_cmds "github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/go-go-golems/glazed/pkg/help"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// generateCommandTree generates count command files, spread over directories of 100 files,
+// with an alias for every tenth command.
+func generateCommandTree(count int) fstest.MapFS {
+	ret := fstest.MapFS{}
+	for i := 0; i < count; i++ {
+		dir := fmt.Sprintf("group%03d", i/100)
+		name := fmt.Sprintf("cmd%05d", i)
+		ret[dir+"/"+name+".yaml"] = &fstest.MapFile{
+			Data: []byte(fmt.Sprintf(`name: %s
+short: Command %d
+long: |
+  A generated command used to benchmark loading large repositories.
+flags:
+  - name: limit
+    type: int
+    default: %d
+  - name: format
+    type: choice
+    choices: [table, json, yaml]
+arguments:
+  - name: pattern
+    type: string
+query: SELECT * FROM table_%d LIMIT {{ .limit }}
+`, name, i, i, i)),
+		}
+		if i%10 == 0 {
+			ret[dir+"/"+name+"/short.yaml"] = &fstest.MapFile{
+				Data: []byte(fmt.Sprintf("name: short\naliasFor: %s\nflags:\n  limit: \"5\"\n", name)),
+			}
+		}
+	}
+	// unparseable files are skipped in both paths
+	ret["group000/broken.yaml"] = &fstest.MapFile{Data: []byte("name: [\n")}
+	return ret
+}
+
+func commandPaths(commands []glazed_cmds.Command) []string {
+	ret := []string{}
+	for _, c := range commands {
+		if a, ok := c.(*alias.CommandAlias); ok {
+			ret = append(ret, fmt.Sprint(a.Parents, a.Name, a.Source))
+			continue
+		}
+		d := c.Description()
+		ret = append(ret, fmt.Sprint(d.Parents, d.Name, d.Source))
+	}
+	return ret
+}
+
+func TestLoadCommandsFromFSWithWorkersKeepsOrder(t *testing.T) {
+	f := generateCommandTree(1000)
+
+	sequential, err := LoadCommandsFromFS(f, ".", NewRawCommandLoader(), nil, nil)
+	require.NoError(t, err)
+	require.Len(t, sequential, 1100)
+
+	for _, workerCount := range []int{2, 8, 32} {
+		concurrent, err := LoadCommandsFromFSWithWorkers(f, ".", NewRawCommandLoader(), nil, nil, workerCount)
+		require.NoError(t, err)
+		assert.Equal(t, commandPaths(sequential), commandPaths(concurrent), "workers: %d", workerCount)
+	}
+}
+
+// quietLogs disables the per-file debug logs, which would dominate the benchmarks.
+func quietLogs(b *testing.B) {
+	level := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+	b.Cleanup(func() {
+		zerolog.SetGlobalLevel(level)
+	})
+}
+
+func benchmarkLoadCommands(b *testing.B, f fstest.MapFS) {
+	quietLogs(b)
+	for _, workerCount := range []int{1, 4, 8, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workerCount), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, err := LoadCommandsFromFSWithWorkers(f, ".", NewRawCommandLoader(), nil, nil, workerCount)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkLoadCommandsFromFS(b *testing.B) {
+	benchmarkLoadCommands(b, generateCommandTree(10000))
+}
+
+func BenchmarkLoadRepositoryDirectory(b *testing.B) {
+	quietLogs(b)
+	dir := b.TempDir()
+	for name, file := range generateCommandTree(10000) {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			b.Fatal(err)
+		}
+		if err := os.WriteFile(path, file.Data, 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	for _, workerCount := range []int{1, 4, 8, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workerCount), func(b *testing.B) {
+			locations := NewCommandLocations(WithRepositories(dir), WithWorkerCount(workerCount))
+			for i := 0; i < b.N; i++ {
+				_, _, err := NewCommandLoader[glazed_cmds.Command](locations).
+					LoadCommands(NewRawCommandLoader(), help.NewHelpSystem())
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
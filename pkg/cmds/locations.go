@@ -12,6 +12,7 @@ import (
 	"github.com/spf13/viper"
 	"io/fs"
 	"os"
+	"path/filepath"
 )
 
 // This file contains a list of helpers to load commands on application start
@@ -30,6 +31,8 @@ type EmbeddedCommandLocation struct {
 	Name    string
 	Root    string
 	DocRoot string
+	// Prefix is the path below which the commands are mounted, the root by default
+	Prefix []string
 }
 
 // CommandLocations groups all possible sources for loading commands on appplication start
@@ -37,14 +40,24 @@ type EmbeddedCommandLocation struct {
 type CommandLocations struct {
 	// List of embedded filesystems to load commands from
 	Embedded []EmbeddedCommandLocation
-	// List of repositories directories
+	// List of repository directories, or .zip/.tar.gz command archives (see OpenArchive)
 	Repositories []string
+	// List of repositories mounted below a prefix, loaded after Repositories
+	Mounts []RepositoryMount
 	// List of additional layers to add to every command
 	AdditionalLayers []layers.ParameterLayer
 	// Help system to register commands with
 	HelpSystem *help.HelpSystem
 	// Load embedded commands first
 	LoadEmbeddedFirst bool
+	// Cache to store the parsed commands of repository directories in, see CommandCache
+	Cache *CommandCache
+	// Number of files to parse concurrently, files are parsed one at a time if it is 0 or 1
+	WorkerCount int
+	// Skip the locations that can't be loaded instead of failing, see CommandLoader.LoadErrors
+	Lenient bool
+	// Patterns in gitignore syntax of the files to skip, in addition to the IgnoreFileName of every location
+	Exclude []string
 }
 
 type LoadCommandsOption func(*CommandLocations)
@@ -81,12 +94,54 @@ func WithRepositories(locations ...string) LoadCommandsOption {
 	}
 }
 
+// WithMount mounts the commands of a repository directory or archive below prefix.
+func WithMount(prefix []string, repository string) LoadCommandsOption {
+	return func(c *CommandLocations) {
+		c.Mounts = append(c.Mounts, RepositoryMount{
+			Prefix:     prefix,
+			Repository: repository,
+		})
+	}
+}
+
 func WithAdditionalLayers(layers ...layers.ParameterLayer) LoadCommandsOption {
 	return func(c *CommandLocations) {
 		c.AdditionalLayers = append(c.AdditionalLayers, layers...)
 	}
 }
 
+// WithCommandCache caches the commands parsed from repository directories in cache.
+// The cache is only used if the loader implements SerializableCommandLoader.
+func WithCommandCache(cache *CommandCache) LoadCommandsOption {
+	return func(c *CommandLocations) {
+		c.Cache = cache
+	}
+}
+
+// WithWorkerCount parses up to workerCount files concurrently.
+// The loader passed to LoadCommands must be safe for concurrent use.
+func WithWorkerCount(workerCount int) LoadCommandsOption {
+	return func(c *CommandLocations) {
+		c.WorkerCount = workerCount
+	}
+}
+
+// WithLenient skips the embedded filesystems, repositories and help sections that can't be
+// loaded, instead of failing. Files that can't be parsed are always skipped.
+func WithLenient(lenient bool) LoadCommandsOption {
+	return func(c *CommandLocations) {
+		c.Lenient = lenient
+	}
+}
+
+// WithExclude skips the files matching the given gitignore patterns, relative to the root
+// of every location.
+func WithExclude(patterns ...string) LoadCommandsOption {
+	return func(c *CommandLocations) {
+		c.Exclude = append(c.Exclude, patterns...)
+	}
+}
+
 func WithHelpSystem(helpSystem *help.HelpSystem) LoadCommandsOption {
 	return func(c *CommandLocations) {
 		c.HelpSystem = helpSystem
@@ -96,13 +151,63 @@ func WithHelpSystem(helpSystem *help.HelpSystem) LoadCommandsOption {
 // CommandLoader wraps a series of command locations and can be ued to load them all at once.
 // This is usually used to load all available commands on startup.
 type CommandLoader[T glazed_cmds.Command] struct {
-	locations *CommandLocations
+	locations   *CommandLocations
+	loadErrors  []*LoadError
+	provenances *Provenances
 }
 
 func NewCommandLoader[T glazed_cmds.Command](locations *CommandLocations) *CommandLoader[T] {
 	return &CommandLoader[T]{
-		locations: locations,
+		locations:   locations,
+		provenances: NewProvenances(),
+	}
+}
+
+// Provenances returns the provenances of the commands loaded by the last call to LoadCommands.
+func (c *CommandLoader[T]) Provenances() *Provenances {
+	return c.provenances
+}
+
+// LoadErrors returns the files and locations that were skipped by the last call to LoadCommands.
+func (c *CommandLoader[T]) LoadErrors() []*LoadError {
+	return c.loadErrors
+}
+
+// skip records that file could not be loaded. It returns err if the loader is not lenient.
+func (c *CommandLoader[T]) skip(file string, err error) error {
+	loadError := NewLoadError(file, err)
+	c.loadErrors = append(c.loadErrors, loadError)
+	if !c.locations.Lenient {
+		return loadError
 	}
+	log.Warn().Err(err).Str("file", file).Msg("Skipping location that could not be loaded")
+	return nil
+}
+
+// loadFS loads the commands of f, recording their provenance and the files that could not be loaded.
+// prefix is the prefix of the sources of the commands, and is prepended to the file names in
+// the load errors. The provenance paths are relative to root, see Provenances.RecordFS.
+func (c *CommandLoader[T]) loadFS(
+	f fs.FS, dir string, prefix string,
+	loader loaders.CommandLoader,
+	options []glazed_cmds.CommandDescriptionOption,
+	aliasOptions []alias.Option,
+	kind LocationKind, location string, root string,
+) ([]glazed_cmds.Command, error) {
+	commands, loadErrors, err := loadCommandsFromFS(
+		f, dir, loader, options, aliasOptions,
+		c.locations.WorkerCount, c.locations.Exclude,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, loadError := range loadErrors {
+		loadError.File = prefix + loadError.File
+		log.Warn().Err(loadError.Err).Str("file", loadError.File).Msg("Could not load command from file")
+	}
+	c.loadErrors = append(c.loadErrors, loadErrors...)
+	c.provenances.RecordFS(f, commands, prefix, kind, location, root)
+	return commands, nil
 }
 
 func (c *CommandLoader[T]) LoadCommands(
@@ -118,6 +223,8 @@ func (c *CommandLoader[T]) LoadCommands(
 
 	commands := make([]T, 0)
 	aliases := make([]*alias.CommandAlias, 0)
+	c.loadErrors = []*LoadError{}
+	c.provenances = NewProvenances()
 
 	embeddedCommands, embeddedAliases, err := c.loadEmbeddedCommands(loader, helpSystem, options...)
 	if err != nil {
@@ -166,9 +273,20 @@ func (c *CommandLoader[T]) loadEmbeddedCommands(
 			alias.WithPrependSource("embed:" + e.Name + ":"),
 			alias.WithStripParentsPrefix([]string{e.Root}),
 		}
-		commands_, err := loaders.LoadCommandsFromFS(e.FS, e.Root, loader, options_, aliasOptions)
+		if len(e.Prefix) > 0 {
+			options_ = append(options_, WithMountPrefix(e.Prefix...))
+			aliasOptions = append(aliasOptions, WithAliasMountPrefix(e.Prefix...))
+		}
+		commands_, err := c.loadFS(
+			e.FS, e.Root, "embed:"+e.Name+":",
+			loader, options_, aliasOptions,
+			LocationEmbedded, e.Name, "",
+		)
 		if err != nil {
-			return nil, nil, err
+			if err = c.skip("embed:"+e.Name+":"+e.Root, err); err != nil {
+				return nil, nil, err
+			}
+			continue
 		}
 		for _, command := range commands_ {
 			switch v := command.(type) {
@@ -178,7 +296,10 @@ func (c *CommandLoader[T]) loadEmbeddedCommands(
 			case T:
 				commands = append(commands, v)
 			default:
-				return nil, nil, errors.New(fmt.Sprintf("unknown command type %T", v))
+				err = c.skip(command.Description().Source, errors.New(fmt.Sprintf("unknown command type %T", v)))
+				if err != nil {
+					return nil, nil, err
+				}
 			}
 		}
 
@@ -187,7 +308,9 @@ func (c *CommandLoader[T]) loadEmbeddedCommands(
 			// if err is PathError, it means that the directory does not exist
 			// and we can safely ignore it
 			if _, ok := err.(*fs.PathError); !ok {
-				return nil, nil, err
+				if err = c.skip("embed:"+e.Name+":"+e.DocRoot, err); err != nil {
+					return nil, nil, err
+				}
 			}
 		}
 	}
@@ -204,8 +327,15 @@ func (c *CommandLoader[T]) loadRepositoryCommands(
 	commands := make([]T, 0)
 	aliases := make([]*alias.CommandAlias, 0)
 
+	mounts := []RepositoryMount{}
 	for _, repository := range c.locations.Repositories {
-		// check that repository exists and is a directory
+		mounts = append(mounts, RepositoryMount{Repository: repository})
+	}
+	mounts = append(mounts, c.locations.Mounts...)
+
+	for _, mount := range mounts {
+		repository := mount.Repository
+		// check that repository exists and is a directory or a command bundle
 		s, err := os.Stat(repository)
 
 		if os.IsNotExist(err) {
@@ -213,53 +343,103 @@ func (c *CommandLoader[T]) loadRepositoryCommands(
 			continue
 		} else if err != nil {
 			log.Warn().Msgf("Error while checking directory %s: %s", repository, err)
+			c.loadErrors = append(c.loadErrors, NewLoadError(repository, err))
 			continue
 		}
 
-		if s == nil || !s.IsDir() {
-			log.Warn().Msgf("Repository %s is not a directory", repository)
-		} else {
-			docDir := fmt.Sprintf("%s/doc", repository)
-			options_ := append(options,
-				glazed_cmds.WithPrependSource(repository+"/"),
-				glazed_cmds.WithStripParentsPrefix([]string{"."}),
-			)
-			aliasOptions := []alias.Option{
-				alias.WithPrependSource(repository + "/"),
+		var repositoryFS fs.FS
+		var cachingLoader_ *cachingLoader
+		repositoryLoader := loader
+		switch {
+		case s.IsDir():
+			repositoryFS = os.DirFS(repository)
+			if serializableLoader, ok := loader.(SerializableCommandLoader); ok && c.locations.Cache != nil {
+				cachingLoader_ = c.locations.Cache.openRepository(absPath(repository), serializableLoader)
+				repositoryLoader = cachingLoader_
 			}
-			commands_, err := loaders.LoadCommandsFromFS(
-				os.DirFS(repository),
-				".",
-				loader,
-				options_,
-				aliasOptions,
-			)
+		case IsArchive(repository):
+			repositoryFS, err = OpenArchive(repository)
 			if err != nil {
-				return nil, nil, err
-			}
-
-			for _, command := range commands_ {
-				switch v := command.(type) {
-				case *alias.CommandAlias:
-					aliases = append(aliases, v)
-				case T:
-					commands = append(commands, v)
-				}
+				log.Warn().Err(err).Msgf("Error while opening archive %s", repository)
+				c.loadErrors = append(c.loadErrors, NewLoadError(repository, err))
+				continue
 			}
+		default:
+			log.Warn().Msgf("Repository %s is neither a directory nor a command archive", repository)
+			c.loadErrors = append(c.loadErrors,
+				NewLoadError(repository, errors.New("neither a directory nor a command archive")))
+			continue
+		}
 
-			_, err = os.Stat(docDir)
-			if os.IsNotExist(err) {
-				continue
-			} else if err != nil {
-				log.Debug().Err(err).Msgf("Error while checking directory %s", docDir)
-				continue
+		options_ := append(append([]glazed_cmds.CommandDescriptionOption{}, options...),
+			glazed_cmds.WithPrependSource(repository+"/"),
+			glazed_cmds.WithStripParentsPrefix([]string{"."}),
+		)
+		aliasOptions := []alias.Option{
+			alias.WithPrependSource(repository + "/"),
+			alias.WithStripParentsPrefix([]string{"."}),
+		}
+		if len(mount.Prefix) > 0 {
+			options_ = append(options_, WithMountPrefix(mount.Prefix...))
+			aliasOptions = append(aliasOptions, WithAliasMountPrefix(mount.Prefix...))
+		}
+		commands_, err := c.loadFS(
+			repositoryFS,
+			".",
+			repository+"/",
+			repositoryLoader,
+			options_,
+			aliasOptions,
+			LocationRepository, repository, absPath(repository),
+		)
+		if err != nil {
+			if err = c.skip(repository, err); err != nil {
+				return nil, nil, err
 			}
-			err = helpSystem.LoadSectionsFromFS(os.DirFS(docDir), ".")
+			continue
+		}
+		if cachingLoader_ != nil {
+			err = c.locations.Cache.save(absPath(repository), cachingLoader_)
 			if err != nil {
-				log.Warn().Err(err).Msgf("Error while loading help sections from directory %s", repository)
-				continue
+				log.Warn().Err(err).Msgf("Could not save command cache for %s", repository)
+			}
+		}
+
+		for _, command := range commands_ {
+			switch v := command.(type) {
+			case *alias.CommandAlias:
+				aliases = append(aliases, v)
+			case T:
+				commands = append(commands, v)
 			}
 		}
+
+		_, err = fs.Stat(repositoryFS, "doc")
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			log.Debug().Err(err).Msgf("Error while checking directory %s/doc", repository)
+			continue
+		}
+		docFS, err := fs.Sub(repositoryFS, "doc")
+		if err != nil {
+			log.Debug().Err(err).Msgf("Error while opening directory %s/doc", repository)
+			continue
+		}
+		err = helpSystem.LoadSectionsFromFS(docFS, ".")
+		if err != nil {
+			log.Warn().Err(err).Msgf("Error while loading help sections from directory %s", repository)
+			c.loadErrors = append(c.loadErrors, NewLoadError(repository+"/doc", err))
+			continue
+		}
 	}
 	return commands, aliases, nil
 }
+
+func absPath(path string) string {
+	ret, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return ret
+}
@@ -0,0 +1,238 @@
+package cmds
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryFS is a read-only fs.FS of files that are kept in memory, or read on demand,
+// used for archives and other sources that are not directories on disk.
+//
+// Directories are created for the parents of every file. All files have to be added
+// before the FS is used, after which it is safe for concurrent use.
+type MemoryFS struct {
+	files map[string]*memoryFile
+	// dirs maps every directory to the names of its children
+	dirs     map[string]map[string]bool
+	dirTimes map[string]time.Time
+}
+
+type memoryFile struct {
+	size    int64
+	modTime time.Time
+	read    func() ([]byte, error)
+
+	once sync.Once
+	data []byte
+	err  error
+}
+
+func (f *memoryFile) content() ([]byte, error) {
+	f.once.Do(func() {
+		if f.read != nil {
+			f.data, f.err = f.read()
+		}
+	})
+	return f.data, f.err
+}
+
+var _ fs.ReadDirFS = (*MemoryFS)(nil)
+var _ fs.ReadFileFS = (*MemoryFS)(nil)
+var _ fs.StatFS = (*MemoryFS)(nil)
+
+func NewMemoryFS() *MemoryFS {
+	return &MemoryFS{
+		files:    map[string]*memoryFile{},
+		dirs:     map[string]map[string]bool{".": {}},
+		dirTimes: map[string]time.Time{},
+	}
+}
+
+// AddFile adds the file name, a slash separated path, with the given content.
+func (m *MemoryFS) AddFile(name string, data []byte, modTime time.Time) {
+	f := &memoryFile{size: int64(len(data)), modTime: modTime}
+	f.once.Do(func() {
+		f.data = data
+	})
+	m.addFile(name, f)
+}
+
+// AddLazyFile adds the file name, whose content is read the first time the file is opened.
+func (m *MemoryFS) AddLazyFile(name string, size int64, modTime time.Time, read func() ([]byte, error)) {
+	m.addFile(name, &memoryFile{size: size, modTime: modTime, read: read})
+}
+
+// AddDir adds the directory name, which is useful for empty directories and to set
+// the modification time of a directory.
+func (m *MemoryFS) AddDir(name string, modTime time.Time) {
+	m.addDir(path.Clean(name))
+	m.dirTimes[path.Clean(name)] = modTime
+}
+
+func (m *MemoryFS) addFile(name string, f *memoryFile) {
+	name = path.Clean(name)
+	m.files[name] = f
+	dir := path.Dir(name)
+	m.addDir(dir)
+	m.dirs[dir][path.Base(name)] = true
+}
+
+func (m *MemoryFS) addDir(name string) {
+	if _, ok := m.dirs[name]; ok {
+		return
+	}
+	m.dirs[name] = map[string]bool{}
+	parent := path.Dir(name)
+	m.addDir(parent)
+	m.dirs[parent][path.Base(name)] = true
+}
+
+func (m *MemoryFS) stat(name string) (*memoryFileInfo, bool) {
+	if f, ok := m.files[name]; ok {
+		return &memoryFileInfo{name: path.Base(name), size: f.size, modTime: f.modTime, mode: 0444}, true
+	}
+	if _, ok := m.dirs[name]; ok {
+		return &memoryFileInfo{name: path.Base(name), modTime: m.dirTimes[name], mode: fs.ModeDir | 0555}, true
+	}
+	return nil, false
+}
+
+func (m *MemoryFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	info, ok := m.stat(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return info, nil
+}
+
+func (m *MemoryFS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	f, ok := m.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	data, err := f.content()
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	// the content is shared by all readers
+	return append([]byte{}, data...), nil
+}
+
+func (m *MemoryFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	children, ok := m.dirs[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	names := make([]string, 0, len(children))
+	for child := range children {
+		names = append(names, child)
+	}
+	sort.Strings(names)
+	ret := make([]fs.DirEntry, 0, len(names))
+	for _, child := range names {
+		info, _ := m.stat(path.Join(name, child))
+		ret = append(ret, info)
+	}
+	return ret, nil
+}
+
+func (m *MemoryFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	info, ok := m.stat(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	if info.IsDir() {
+		entries, err := m.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		return &memoryDir{info: info, path: name, entries: entries}, nil
+	}
+
+	data, err := m.files[name].content()
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	info.size = int64(len(data))
+	return &memoryOpenFile{info: info, Reader: bytes.NewReader(data)}, nil
+}
+
+type memoryFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	mode    fs.FileMode
+}
+
+var _ fs.FileInfo = (*memoryFileInfo)(nil)
+var _ fs.DirEntry = (*memoryFileInfo)(nil)
+
+func (i *memoryFileInfo) Name() string               { return i.name }
+func (i *memoryFileInfo) Size() int64                { return i.size }
+func (i *memoryFileInfo) Mode() fs.FileMode          { return i.mode }
+func (i *memoryFileInfo) ModTime() time.Time         { return i.modTime }
+func (i *memoryFileInfo) IsDir() bool                { return i.mode.IsDir() }
+func (i *memoryFileInfo) Sys() interface{}           { return nil }
+func (i *memoryFileInfo) Type() fs.FileMode          { return i.mode.Type() }
+func (i *memoryFileInfo) Info() (fs.FileInfo, error) { return i, nil }
+
+type memoryOpenFile struct {
+	info *memoryFileInfo
+	*bytes.Reader
+}
+
+func (f *memoryOpenFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memoryOpenFile) Close() error               { return nil }
+
+type memoryDir struct {
+	info    *memoryFileInfo
+	path    string
+	entries []fs.DirEntry
+	offset  int
+}
+
+var _ fs.ReadDirFile = (*memoryDir)(nil)
+
+func (d *memoryDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *memoryDir) Close() error               { return nil }
+
+func (d *memoryDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.path, Err: fs.ErrInvalid}
+}
+
+func (d *memoryDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := len(d.entries) - d.offset
+	if n <= 0 {
+		ret := d.entries[d.offset:]
+		d.offset = len(d.entries)
+		return ret, nil
+	}
+	if remaining == 0 {
+		return nil, io.EOF
+	}
+	if n > remaining {
+		n = remaining
+	}
+	ret := d.entries[d.offset : d.offset+n]
+	d.offset += n
+	return ret, nil
+}
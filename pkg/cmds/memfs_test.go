@@ -0,0 +1,48 @@
+package cmds
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestMemoryFS(t *testing.T) {
+	reads := 0
+	f := NewMemoryFS()
+	f.AddFile("users.yaml", []byte("name: users\n"), time.Time{})
+	f.AddFile("ops/hosts.yaml", []byte("name: hosts\n"), time.Time{})
+	f.AddLazyFile("ops/deploy/list.yaml", 11, time.Time{}, func() ([]byte, error) {
+		reads++
+		return []byte("name: list\n"), nil
+	})
+	f.AddDir("empty", time.Time{})
+
+	require.NoError(t, fstest.TestFS(f, "users.yaml", "ops/hosts.yaml", "ops/deploy/list.yaml", "empty"))
+
+	content, err := fs.ReadFile(f, "ops/deploy/list.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "name: list\n", string(content))
+	// lazy files are only read once
+	assert.Equal(t, 1, reads)
+
+	_, err = f.Open("missing.yaml")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestMemoryFSLazyFileIsNotReadWhenListing(t *testing.T) {
+	f := NewMemoryFS()
+	f.AddLazyFile("users.yaml", 12, time.Time{}, func() ([]byte, error) {
+		t.Fatal("file was read")
+		return nil, nil
+	})
+
+	entries, err := fs.ReadDir(f, ".")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	info, err := entries[0].Info()
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), info.Size())
+}
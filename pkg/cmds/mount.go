@@ -0,0 +1,30 @@
+package cmds
+
+import (
+	glazed_cmds "github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+)
+
+// RepositoryMount mounts the commands of a repository directory or archive below Prefix,
+// so that independent repositories don't collide at the top level.
+type RepositoryMount struct {
+	Prefix     []string
+	Repository string
+}
+
+// WithMountPrefix prepends prefix to the parents of a command.
+//
+// Unlike glazed's WithPrependParents, it copies prefix for every command,
+// so that commands sharing the option never share their parents.
+func WithMountPrefix(prefix ...string) glazed_cmds.CommandDescriptionOption {
+	return func(c *glazed_cmds.CommandDescription) {
+		c.Parents = append(append([]string{}, prefix...), c.Parents...)
+	}
+}
+
+// WithAliasMountPrefix prepends prefix to the parents of an alias.
+func WithAliasMountPrefix(prefix ...string) alias.Option {
+	return func(a *alias.CommandAlias) {
+		a.Parents = append(append([]string{}, prefix...), a.Parents...)
+	}
+}
@@ -0,0 +1,56 @@
+package cmds
+
+import (
+	glazed_cmds "github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/help"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMountedLocations(t *testing.T) {
+	dir := t.TempDir()
+	writeCacheTestFile(t, dir, "users.yaml", "name: users\n")
+	writeCacheTestFile(t, dir, "ops/hosts.yaml", "name: hosts\n")
+	writeCacheTestFile(t, dir, "users/active.yaml", "name: active\naliasFor: users\n")
+
+	embedded := EmbeddedCommandLocation{
+		FS: fstest.MapFS{
+			"queries/users.yaml": {Data: []byte("name: users\n")},
+		},
+		Name:   "builtin",
+		Root:   "queries",
+		Prefix: []string{"builtin"},
+	}
+
+	locations := NewCommandLocations(
+		WithEmbeddedLocations(embedded),
+		WithRepositories(dir),
+		WithMount([]string{"teams", "ops"}, dir),
+	)
+	commands, aliases, err := NewCommandLoader[glazed_cmds.Command](locations).
+		LoadCommands(NewRawCommandLoader(), help.NewHelpSystem())
+	require.NoError(t, err)
+
+	paths := []string{}
+	for _, c := range commands {
+		d := c.Description()
+		paths = append(paths, strings.Join(append(append([]string{}, d.Parents...), d.Name), " "))
+	}
+	for _, a := range aliases {
+		paths = append(paths, strings.Join(append(append([]string{}, a.Parents...), a.Name), " "))
+	}
+	sort.Strings(paths)
+	assert.Equal(t, []string{
+		"builtin users",
+		"ops hosts",
+		"teams ops ops hosts",
+		"teams ops users",
+		"teams ops users active",
+		"users",
+		"users active",
+	}, paths)
+}
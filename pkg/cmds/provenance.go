@@ -0,0 +1,156 @@
+package cmds
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	glazed_cmds "github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/rs/zerolog/log"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The provenance of a command records where it was loaded from, so that applications can
+// tell which of several definitions of the same command is in use, see `clay repo which`.
+//
+// Provenances are recorded by source, which is unique for every loaded file, and thus apply
+// to all the commands and aliases defined in that file. Loading a file again replaces its
+// provenance. Every CommandLoader and repository keeps the provenances of the commands it
+// loaded, so that they go away with it, and repositories drop the provenance of removed files.
+
+// LocationKind is the kind of location a command was loaded from.
+type LocationKind string
+
+const (
+	LocationEmbedded   LocationKind = "embedded"
+	LocationRepository LocationKind = "repository"
+	LocationInputFile  LocationKind = "input-file"
+	LocationPlugin     LocationKind = "plugin"
+)
+
+type Provenance struct {
+	Kind LocationKind `json:"kind"`
+	// Location is the name of the location, for example the name of the embedded filesystem
+	// or the repository directory
+	Location string `json:"location"`
+	// Path is the absolute path of the file, or its path inside the embedded filesystem
+	Path     string    `json:"path"`
+	LoadedAt time.Time `json:"loadedAt"`
+	// Hash is the hex encoded SHA-256 of the content of the file
+	Hash string `json:"hash"`
+}
+
+// Provenances stores the provenance of commands by source. It is safe for concurrent use.
+type Provenances struct {
+	mu      sync.RWMutex
+	sources map[string]*Provenance
+}
+
+func NewProvenances() *Provenances {
+	return &Provenances{
+		sources: map[string]*Provenance{},
+	}
+}
+
+// HashContent returns the hash stored in Provenance.Hash for content.
+func HashContent(content []byte) string {
+	h := sha256.Sum256(content)
+	return hex.EncodeToString(h[:])
+}
+
+// Record records the provenance of the commands and aliases whose source is source.
+func (p *Provenances) Record(source string, provenance *Provenance) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sources[source] = provenance
+}
+
+// Remove drops the provenance of source.
+func (p *Provenances) Remove(source string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.sources, source)
+}
+
+// Merge records all the provenances of other.
+func (p *Provenances) Merge(other *Provenances) {
+	other.mu.RLock()
+	defer other.mu.RUnlock()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for source, provenance := range other.sources {
+		p.sources[source] = provenance
+	}
+}
+
+// Sources returns the sources that have a provenance, sorted.
+func (p *Provenances) Sources() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	ret := make([]string, 0, len(p.sources))
+	for source := range p.sources {
+		ret = append(ret, source)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// GetSource returns the provenance recorded for source.
+func (p *Provenances) GetSource(source string) (*Provenance, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	ret, ok := p.sources[source]
+	return ret, ok
+}
+
+// Get returns the provenance of a command or alias.
+func (p *Provenances) Get(command glazed_cmds.Command) (*Provenance, bool) {
+	source := CommandSource(command)
+	if source == "" {
+		return nil, false
+	}
+	return p.GetSource(source)
+}
+
+// RecordFS records the provenance of commands loaded from f, whose sources are
+// sourcePrefix followed by the name of their file in f. The path of the files is relative
+// to root, or the name of the file in f if root is empty.
+func (p *Provenances) RecordFS(
+	f fs.FS,
+	commands []glazed_cmds.Command,
+	sourcePrefix string,
+	kind LocationKind,
+	location string,
+	root string,
+) {
+	loadedAt := time.Now()
+	seen := map[string]bool{}
+	for _, command := range commands {
+		source := CommandSource(command)
+		if seen[source] || !strings.HasPrefix(source, sourcePrefix) {
+			continue
+		}
+		seen[source] = true
+
+		fileName := strings.TrimPrefix(source, sourcePrefix)
+		content, err := fs.ReadFile(f, filepath.ToSlash(fileName))
+		if err != nil {
+			log.Debug().Err(err).Str("source", source).Msg("Could not hash command file")
+			continue
+		}
+		path := fileName
+		if root != "" {
+			path = filepath.Join(root, fileName)
+		}
+		p.Record(source, &Provenance{
+			Kind:     kind,
+			Location: location,
+			Path:     path,
+			LoadedAt: loadedAt,
+			Hash:     HashContent(content),
+		})
+	}
+}
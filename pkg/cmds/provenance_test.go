@@ -0,0 +1,73 @@
+package cmds
+
+import (
+	glazed_cmds "github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/help"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCommandLoaderRecordsProvenance(t *testing.T) {
+	dir := t.TempDir()
+	writeCacheTestFile(t, dir, "ops/hosts.yaml", "name: hosts\n")
+	writeCacheTestFile(t, dir, "users/active.yaml", "name: active\naliasFor: users\n")
+
+	embedded := EmbeddedCommandLocation{
+		FS: fstest.MapFS{
+			"queries/users.yaml": {Data: []byte("name: users\n")},
+		},
+		Name: "provenance-test",
+		Root: "queries",
+	}
+
+	locations := NewCommandLocations(
+		WithEmbeddedLocations(embedded),
+		WithRepositories(dir),
+	)
+	loader := NewCommandLoader[glazed_cmds.Command](locations)
+	commands, aliases, err := loader.LoadCommands(NewRawCommandLoader(), help.NewHelpSystem())
+	require.NoError(t, err)
+
+	provenances := map[string]*Provenance{}
+	for _, c := range commands {
+		p, ok := loader.Provenances().Get(c)
+		require.True(t, ok, c.Description().Name)
+		provenances[c.Description().Name] = p
+	}
+	require.Len(t, aliases, 1)
+	p, ok := loader.Provenances().Get(aliases[0])
+	require.True(t, ok)
+	provenances[aliases[0].Name] = p
+
+	users := provenances["users"]
+	require.NotNil(t, users)
+	assert.Equal(t, LocationEmbedded, users.Kind)
+	assert.Equal(t, "provenance-test", users.Location)
+	assert.Equal(t, "queries/users.yaml", users.Path)
+	assert.Equal(t, HashContent([]byte("name: users\n")), users.Hash)
+	assert.False(t, users.LoadedAt.IsZero())
+
+	hosts := provenances["hosts"]
+	require.NotNil(t, hosts)
+	assert.Equal(t, LocationRepository, hosts.Kind)
+	assert.Equal(t, absPath(dir), hosts.Location)
+	assert.Equal(t, filepath.Join(absPath(dir), "ops/hosts.yaml"), hosts.Path)
+	assert.Equal(t, HashContent([]byte("name: hosts\n")), hosts.Hash)
+
+	active := provenances["active"]
+	require.NotNil(t, active)
+	assert.Equal(t, LocationRepository, active.Kind)
+	assert.Equal(t, filepath.Join(absPath(dir), "users/active.yaml"), active.Path)
+
+	// loading again only keeps the provenances of the files that are still there
+	require.NoError(t, os.Remove(filepath.Join(dir, "ops/hosts.yaml")))
+	_, _, err = loader.LoadCommands(NewRawCommandLoader(), help.NewHelpSystem())
+	require.NoError(t, err)
+	_, ok = loader.Provenances().GetSource(filepath.Join(absPath(dir), "ops/hosts.yaml"))
+	assert.False(t, ok)
+	assert.Len(t, loader.Provenances().Sources(), 2)
+}
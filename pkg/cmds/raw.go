@@ -1,10 +1,14 @@
 package cmds
 
 import (
+	"bytes"
+	"encoding/json"
 	"github.com/go-go-golems/glazed/pkg/cmds"
 	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
 	"github.com/go-go-golems/glazed/pkg/cmds/layout"
 	"github.com/go-go-golems/glazed/pkg/cmds/loaders"
+	"github.com/pkg/errors"
 	"gopkg.in/yaml.v3"
 	"io"
 	"io/fs"
@@ -14,6 +18,7 @@ import (
 type RawCommandLoader struct{}
 
 var _ loaders.CommandLoader = (*RawCommandLoader)(nil)
+var _ SerializableCommandLoader = (*RawCommandLoader)(nil)
 
 type RawCommand struct {
 	*cmds.CommandDescription
@@ -64,12 +69,20 @@ func loadRawCommand(
 	if err != nil {
 		return nil, err
 	}
+	// raw commands don't necessarily declare layers, but glazed expects them to be present
+	if description.Layers == nil {
+		description.Layers = layers.NewParameterLayers()
+	}
 
 	allYaml := map[string]interface{}{}
 	err = yaml.Unmarshal(buf, &allYaml)
 	if err != nil {
 		return nil, err
 	}
+	// let LoadCommandOrAliasFromReader load the file as an alias
+	if _, ok := allYaml["aliasFor"]; ok {
+		return nil, errors.New("file is an alias")
+	}
 
 	for _, option := range options {
 		option(description)
@@ -91,3 +104,127 @@ func (r2 *RawCommandLoader) IsFileSupported(f fs.FS, fileName string) bool {
 func NewRawCommandLoader() loaders.CommandLoader {
 	return &RawCommandLoader{}
 }
+
+// rawCommandCacheEntry is the form in which RawCommandLoader stores commands in the command cache.
+type rawCommandCacheEntry struct {
+	// AliasYAML is set if the file defines an alias
+	AliasYAML []byte `json:"aliasYAML,omitempty"`
+
+	Name           string                 `json:"name,omitempty"`
+	Short          string                 `json:"short,omitempty"`
+	Long           string                 `json:"long,omitempty"`
+	AdditionalData map[string]interface{} `json:"additionalData,omitempty"`
+	Parents        []string               `json:"parents,omitempty"`
+	Source         string                 `json:"source,omitempty"`
+	YAMLContent    map[string]interface{} `json:"yamlContent"`
+	Content        []byte                 `json:"content"`
+}
+
+func (r *RawCommandLoader) SerializeCommands(commands []cmds.Command) ([]byte, error) {
+	if len(commands) != 1 {
+		return nil, ErrNotCacheable
+	}
+
+	entry := &rawCommandCacheEntry{}
+	switch c := commands[0].(type) {
+	case *alias.CommandAlias:
+		buf := &bytes.Buffer{}
+		err := c.ToYAML(buf)
+		if err != nil {
+			return nil, err
+		}
+		entry.AliasYAML = buf.Bytes()
+	case *RawCommand:
+		// layers and layouts can't be restored from JSON
+		if c.Layout != nil || c.CommandDescription.Layout != nil || len(c.Layers.AsList()) > 0 {
+			return nil, ErrNotCacheable
+		}
+		entry.Name = c.Name
+		entry.Short = c.Short
+		entry.Long = c.Long
+		entry.AdditionalData = c.AdditionalData
+		entry.Parents = c.Parents
+		entry.Source = c.Source
+		entry.YAMLContent = c.YAMLContent
+		entry.Content = c.Content
+	default:
+		return nil, ErrNotCacheable
+	}
+
+	return json.Marshal(entry)
+}
+
+func (r *RawCommandLoader) DeserializeCommands(
+	data []byte,
+	options []cmds.CommandDescriptionOption,
+	aliasOptions []alias.Option,
+) ([]cmds.Command, error) {
+	entry := &rawCommandCacheEntry{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err := decoder.Decode(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.AliasYAML != nil {
+		aliases, err := loaders.LoadCommandAliasFromYAML(bytes.NewReader(entry.AliasYAML), aliasOptions...)
+		if err != nil {
+			return nil, err
+		}
+		return []cmds.Command{aliases[0]}, nil
+	}
+
+	description := &cmds.CommandDescription{
+		Name:           entry.Name,
+		Short:          entry.Short,
+		Long:           entry.Long,
+		AdditionalData: fromJSONMap(entry.AdditionalData),
+		Parents:        entry.Parents,
+		Source:         entry.Source,
+		Layers:         layers.NewParameterLayers(),
+	}
+	for _, option := range options {
+		option(description)
+	}
+
+	return []cmds.Command{
+		&RawCommand{
+			CommandDescription: description,
+			YAMLContent:        fromJSONMap(entry.YAMLContent),
+			Content:            entry.Content,
+		},
+	}, nil
+}
+
+// fromJSONMap converts the numbers of a map decoded from JSON back to the ints and floats
+// the YAML decoder returns.
+func fromJSONMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	for k, v := range m {
+		m[k] = fromJSONValue(v)
+	}
+	return m
+}
+
+func fromJSONValue(v interface{}) interface{} {
+	switch v_ := v.(type) {
+	case json.Number:
+		if i, err := v_.Int64(); err == nil {
+			return int(i)
+		}
+		f, _ := v_.Float64()
+		return f
+	case map[string]interface{}:
+		return fromJSONMap(v_)
+	case []interface{}:
+		for i, e := range v_ {
+			v_[i] = fromJSONValue(e)
+		}
+		return v_
+	default:
+		return v
+	}
+}
@@ -0,0 +1,39 @@
+package cmds
+
+import (
+	glazed_cmds "github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRawCommandLoaderLoadsAliases(t *testing.T) {
+	f := fstest.MapFS{
+		"users.yaml":       {Data: []byte("name: users\nshort: List users\n")},
+		"users/mine.yaml":  {Data: []byte("name: mine\naliasFor: active\nflags:\n  owner: me\n")},
+		"users/other.yaml": {Data: []byte("name: other\naliasFor: users\n")},
+	}
+	loader := NewRawCommandLoader()
+
+	commands, err := loader.LoadCommands(f, "users.yaml", []glazed_cmds.CommandDescriptionOption{}, []alias.Option{})
+	require.NoError(t, err)
+	require.Len(t, commands, 1)
+	assert.IsType(t, &RawCommand{}, commands[0])
+
+	commands, err = loader.LoadCommands(f, "users/mine.yaml", []glazed_cmds.CommandDescriptionOption{}, []alias.Option{})
+	require.NoError(t, err)
+	require.Len(t, commands, 1)
+	mine, ok := commands[0].(*alias.CommandAlias)
+	require.True(t, ok)
+	assert.Equal(t, "active", mine.AliasFor)
+
+	commands, err = loader.LoadCommands(f, "users/other.yaml", []glazed_cmds.CommandDescriptionOption{}, []alias.Option{})
+	require.NoError(t, err)
+	active := commands[0].(*alias.CommandAlias)
+	active.Name = "active"
+	active.AliasedCommand = &RawCommand{CommandDescription: glazed_cmds.NewCommandDescription("users")}
+	mine.AliasedCommand = active
+	assert.Equal(t, []string{"mine", "active", "users"}, GetAliasChain(mine))
+}
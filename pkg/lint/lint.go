@@ -0,0 +1,349 @@
+package lint
+
+import (
+	"fmt"
+	claycmds "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/go-go-golems/glazed/pkg/cmds/loaders"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// A Problem is an issue found in a command repository.
+type Problem struct {
+	File     string
+	Severity Severity
+	Message  string
+}
+
+var validParameterTypes = map[parameters.ParameterType]bool{
+	parameters.ParameterTypeString:              true,
+	parameters.ParameterTypeStringFromFile:      true,
+	parameters.ParameterTypeStringFromFiles:     true,
+	parameters.ParameterTypeFile:                true,
+	parameters.ParameterTypeFileList:            true,
+	parameters.ParameterTypeObjectListFromFile:  true,
+	parameters.ParameterTypeObjectListFromFiles: true,
+	parameters.ParameterTypeObjectFromFile:      true,
+	parameters.ParameterTypeStringListFromFile:  true,
+	parameters.ParameterTypeStringListFromFiles: true,
+	parameters.ParameterTypeKeyValue:            true,
+	parameters.ParameterTypeInteger:             true,
+	parameters.ParameterTypeFloat:               true,
+	parameters.ParameterTypeBool:                true,
+	parameters.ParameterTypeDate:                true,
+	parameters.ParameterTypeStringList:          true,
+	parameters.ParameterTypeIntegerList:         true,
+	parameters.ParameterTypeFloatList:           true,
+	parameters.ParameterTypeChoice:              true,
+	parameters.ParameterTypeChoiceList:          true,
+}
+
+// commandParameters is the part of a command YAML file that glazed parses into flags and arguments.
+type commandParameters struct {
+	Flags     []*parameters.ParameterDefinition `yaml:"flags,omitempty"`
+	Arguments []*parameters.ParameterDefinition `yaml:"arguments,omitempty"`
+}
+
+type linter struct {
+	root     string
+	problems []Problem
+	// commands maps the full path of each command to the files defining it
+	commands map[string][]string
+	// aliases maps the full path of each alias to the alias
+	aliases map[string]*alias.CommandAlias
+}
+
+func pathKey(path []string) string {
+	return strings.Join(path, " ")
+}
+
+func (l *linter) report(file string, severity Severity, format string, args ...interface{}) {
+	l.problems = append(l.problems, Problem{
+		File:     file,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Lint statically checks the command repositories in directories.
+//
+// Each directory is checked on its own, since commands overriding the commands of
+// another directory are expected. Lint reports:
+//   - files that can't be loaded
+//   - commands defined more than once at the same path
+//   - aliases whose aliased command doesn't exist, and alias chains containing a cycle
+//   - flags and arguments that glazed can't parse, or that have an invalid type or default
+//   - declared parents that don't match the directory layout, and commands that clash
+//     with a directory of commands of the same name
+//
+// The returned error is only set if a directory can't be walked.
+func Lint(directories []string) ([]Problem, error) {
+	ret := []Problem{}
+	for _, directory := range directories {
+		root, err := filepath.Abs(directory)
+		if err != nil {
+			return nil, err
+		}
+		l := &linter{
+			root:     root,
+			problems: []Problem{},
+			commands: map[string][]string{},
+			aliases:  map[string]*alias.CommandAlias{},
+		}
+		err = l.lint()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, l.problems...)
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].File != ret[j].File {
+			return ret[i].File < ret[j].File
+		}
+		return ret[i].Message < ret[j].Message
+	})
+	return ret, nil
+}
+
+func (l *linter) lint() error {
+	loader := claycmds.NewRawCommandLoader()
+	rootFS := os.DirFS(l.root)
+
+	err := fs.WalkDir(rootFS, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != "." && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		// help sections are not commands
+		if d.IsDir() && path == "doc" {
+			return fs.SkipDir
+		}
+		if d.IsDir() || claycmds.IsGroupFile(path) || !loader.IsFileSupported(rootFS, path) {
+			return nil
+		}
+
+		l.lintFile(rootFS, path, loader)
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "could not walk %s", l.root)
+	}
+
+	l.lintDuplicates()
+	l.lintAliases()
+	l.lintGroups()
+
+	return nil
+}
+
+func (l *linter) lintFile(rootFS fs.FS, path string, loader loaders.CommandLoader) {
+	file := filepath.Join(l.root, filepath.FromSlash(path))
+
+	commands, err := claycmds.LoadCommandsFromFile(rootFS, path, loader, nil, nil)
+	if err != nil {
+		l.report(file, SeverityError, "could not load command: %s", err)
+		return
+	}
+
+	parents := []string{}
+	if dir := filepath.Dir(path); dir != "." {
+		parents = strings.Split(filepath.ToSlash(dir), "/")
+	}
+
+	if alias_, ok := commands[0].(*alias.CommandAlias); ok {
+		alias_.Source = file
+		alias_.Parents = parents
+		l.aliases[pathKey(append(append([]string{}, parents...), alias_.Name))] = alias_
+		return
+	}
+	rawCommand, ok := commands[0].(*claycmds.RawCommand)
+	if !ok {
+		return
+	}
+
+	name := rawCommand.Description().Name
+	if name == "" {
+		l.report(file, SeverityError, "command has no name")
+		return
+	}
+	if declared, ok := rawCommand.YAMLContent["parents"]; ok {
+		if fmt.Sprint(declared) != fmt.Sprint(parents) {
+			l.report(file, SeverityWarning,
+				"declared parents %v don't match the directory layout %v", declared, parents)
+		}
+	}
+
+	fullPath := strings.Join(append(append([]string{}, parents...), name), " ")
+	l.commands[fullPath] = append(l.commands[fullPath], file)
+
+	l.lintParameters(file, rawCommand.Content)
+}
+
+func (l *linter) lintParameters(file string, content []byte) {
+	p := &commandParameters{}
+	err := yaml.Unmarshal(content, p)
+	if err != nil {
+		l.report(file, SeverityError, "could not parse flags and arguments: %s", err)
+		return
+	}
+
+	seen := map[string]bool{}
+	definitions := append(append([]*parameters.ParameterDefinition{}, p.Flags...), p.Arguments...)
+	for _, definition := range definitions {
+		if definition == nil {
+			l.report(file, SeverityError, "empty parameter definition")
+			continue
+		}
+		if definition.Name == "" {
+			l.report(file, SeverityError, "parameter has no name")
+			continue
+		}
+		if seen[definition.Name] {
+			l.report(file, SeverityError, "parameter %s is defined more than once", definition.Name)
+		}
+		seen[definition.Name] = true
+
+		if !validParameterTypes[definition.Type] {
+			l.report(file, SeverityError, "parameter %s has invalid type '%s'", definition.Name, definition.Type)
+			continue
+		}
+		if (definition.Type == parameters.ParameterTypeChoice || definition.Type == parameters.ParameterTypeChoiceList) &&
+			len(definition.Choices) == 0 {
+			l.report(file, SeverityError, "parameter %s is a choice parameter but has no choices", definition.Name)
+			continue
+		}
+		err = definition.CheckParameterDefaultValueValidity()
+		if err != nil {
+			l.report(file, SeverityError, "parameter %s has an invalid default: %s", definition.Name, err)
+		}
+	}
+}
+
+func (l *linter) lintDuplicates() {
+	for path, files := range l.commands {
+		if len(files) < 2 {
+			continue
+		}
+		for _, file := range files {
+			others := []string{}
+			for _, other := range files {
+				if other != file {
+					others = append(others, other)
+				}
+			}
+			l.report(file, SeverityError, "command '%s' is also defined in %s", path, strings.Join(others, ", "))
+		}
+	}
+}
+
+// lintAliases checks that the chain of each alias leads to an existing command.
+// Aliases are stored in a directory named after the command they alias, or next to
+// the alias they extend, in which case aliasFor is the name of that alias.
+func (l *linter) lintAliases() {
+	keys := []string{}
+	for key := range l.aliases {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		alias_ := l.aliases[key]
+		if len(alias_.Parents) == 0 && l.aliasTarget(alias_) == nil {
+			l.report(alias_.Source, SeverityError,
+				"alias %s must be in the directory of the command it aliases", alias_.Name)
+			continue
+		}
+
+		visited := map[string]bool{key: true}
+		chain := []string{key}
+		current := alias_
+		for {
+			target := l.aliasTarget(current)
+			if target == nil {
+				target = current.Parents
+			}
+			targetKey := pathKey(target)
+			chain = append(chain, targetKey)
+			if visited[targetKey] {
+				l.report(alias_.Source, SeverityError,
+					"alias %s: alias cycle: %s", alias_.Name, strings.Join(chain, " -> "))
+				break
+			}
+			visited[targetKey] = true
+
+			if extended, ok := l.aliases[targetKey]; ok {
+				current = extended
+				continue
+			}
+			if _, ok := l.commands[targetKey]; !ok {
+				l.report(alias_.Source, SeverityError,
+					"alias %s: aliased command '%s' does not exist", alias_.Name, targetKey)
+			}
+			break
+		}
+
+		if l.aliasTarget(alias_) == nil && alias_.AliasFor != alias_.Parents[len(alias_.Parents)-1] {
+			l.report(alias_.Source, SeverityWarning,
+				"alias %s: aliasFor '%s' doesn't match the aliased command '%s'",
+				alias_.Name, alias_.AliasFor, pathKey(alias_.Parents))
+		}
+	}
+}
+
+// aliasTarget returns the path of the alias extended by alias_, or nil if alias_
+// aliases the command at its parents.
+func (l *linter) aliasTarget(alias_ *alias.CommandAlias) []string {
+	parents := alias_.Parents
+	if len(parents) > 0 && parents[len(parents)-1] == alias_.AliasFor {
+		return nil
+	}
+	sibling := append(append([]string{}, parents...), alias_.AliasFor)
+	if _, ok := l.aliases[pathKey(sibling)]; ok {
+		return sibling
+	}
+	return nil
+}
+
+// lintGroups reports commands that have the same path as a directory containing other commands,
+// which makes the command path ambiguous. Directories containing only aliases are expected.
+func (l *linter) lintGroups() {
+	for path, files := range l.commands {
+		for other := range l.commands {
+			if strings.HasPrefix(other, path+" ") {
+				l.report(files[0], SeverityWarning,
+					"command '%s' clashes with the directory of commands '%s'", path, path)
+				break
+			}
+		}
+	}
+}
+
+// HasErrors returns true if any of the problems is an error.
+func HasErrors(problems []Problem) bool {
+	for _, p := range problems {
+		if p.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package lint
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, dir string, name string, content string) {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func lintDirectory(t *testing.T, dir string) []string {
+	problems, err := Lint([]string{dir})
+	require.NoError(t, err)
+	ret := []string{}
+	for _, p := range problems {
+		rel, err := filepath.Rel(dir, p.File)
+		require.NoError(t, err)
+		ret = append(ret, rel+": "+string(p.Severity)+": "+p.Message)
+	}
+	return ret
+}
+
+func TestLintCleanRepository(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "ops/users.yaml", `name: users
+short: List users
+flags:
+  - name: limit
+    type: int
+    default: 10
+  - name: format
+    type: choice
+    choices: [table, json]
+    default: table
+arguments:
+  - name: pattern
+    type: string
+`)
+	writeTestFile(t, dir, "ops/users/active.yaml", "name: active\naliasFor: users\nflags:\n  active: true\n")
+	writeTestFile(t, dir, "doc/topics/01-ops.yaml", "not: a command\n")
+
+	problems, err := Lint([]string{dir})
+	require.NoError(t, err)
+	assert.Empty(t, problems)
+	assert.False(t, HasErrors(problems))
+}
+
+func TestLintProblems(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "ops/users.yaml", "name: users\n")
+	writeTestFile(t, dir, "ops/users2.yaml", "name: users\n")
+	writeTestFile(t, dir, "ops/hosts/all.yaml", "name: all\naliasFor: hosts\n")
+	writeTestFile(t, dir, "ops/types.yaml", `name: types
+flags:
+  - name: limit
+    type: integer
+  - name: count
+    type: int
+    default: many
+  - name: format
+    type: choice
+`)
+	writeTestFile(t, dir, "ops/broken.yaml", "name: broken\nflags:\n  limit: int\n")
+	writeTestFile(t, dir, "ops/moved.yaml", "name: moved\nparents: [db]\n")
+	writeTestFile(t, dir, "invalid.yaml", "name: [\n")
+
+	problems := lintDirectory(t, dir)
+	assert.Len(t, problems, 9, problems)
+	assert.Contains(t, problems[0], "invalid.yaml: error: could not load command")
+	assert.Contains(t, problems[1], "ops/broken.yaml: error: could not parse flags and arguments")
+	assert.Equal(t, "ops/hosts/all.yaml: error: alias all: aliased command 'ops hosts' does not exist", problems[2])
+	assert.Equal(t, "ops/moved.yaml: warning: declared parents [db] don't match the directory layout [ops]", problems[3])
+	assert.Contains(t, problems[4], "ops/types.yaml: error: parameter count has an invalid default")
+	assert.Equal(t, "ops/types.yaml: error: parameter format is a choice parameter but has no choices", problems[5])
+	assert.Equal(t, "ops/types.yaml: error: parameter limit has invalid type 'integer'", problems[6])
+	assert.Contains(t, problems[7], "ops/users.yaml: error: command 'ops users' is also defined in")
+	assert.Contains(t, problems[8], "ops/users2.yaml: error: command 'ops users' is also defined in")
+}
+
+func TestLintGroupClash(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "ops.yaml", "name: ops\n")
+	writeTestFile(t, dir, "ops/users.yaml", "name: users\n")
+
+	problems, err := Lint([]string{dir})
+	require.NoError(t, err)
+	require.Len(t, problems, 1)
+	assert.Equal(t, SeverityWarning, problems[0].Severity)
+	assert.False(t, HasErrors(problems))
+}
+
+func TestLintAliasChains(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "ops/users.yaml", "name: users\n")
+	writeTestFile(t, dir, "ops/users/active.yaml", "name: active\naliasFor: users\n")
+	writeTestFile(t, dir, "ops/users/mine.yaml", "name: mine\naliasFor: active\n")
+	writeTestFile(t, dir, "ops/users/a.yaml", "name: a\naliasFor: b\n")
+	writeTestFile(t, dir, "ops/users/b.yaml", "name: b\naliasFor: a\n")
+
+	problems := lintDirectory(t, dir)
+	assert.Equal(t, []string{
+		"ops/users/a.yaml: error: alias a: alias cycle: ops users a -> ops users b -> ops users a",
+		"ops/users/b.yaml: error: alias b: alias cycle: ops users b -> ops users a -> ops users b",
+	}, problems)
+}
@@ -0,0 +1,321 @@
+package fs
+
+import (
+	claycmds "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Aliases are stored in the trie below the command they alias, which means their parents
+// are the full path of the aliased command.
+//
+// An alias can also alias another alias: if its aliasFor names an alias stored next to it,
+// the alias extends that alias instead of the command. The flags and arguments of such a
+// chain are merged in chain order, starting from the alias closest to the command, so that
+// every alias overrides the defaults of the aliases it extends. Chains that loop back onto
+// themselves are reported as errors, see AliasErrors.
+//
+// An alias can only be inserted in the trie once its chain leads to a command, because an
+// unresolved alias doesn't have a description. Aliases whose command is missing, for example
+// because the watcher sees the alias file before the command file, or because the command is
+// defined in a directory that is loaded later, are kept in the pending set. They are resolved
+// as soon as the command is added, and go back to pending when the command is removed.
+//
+// The aliases in the trie are copies of the aliases that were added, since the aliases returned
+// by CollectCommands and FindCommand are shared with the callers and must not be modified.
+// When the resolution of an alias changes, for example because its command was replaced,
+// a new copy is inserted and an EventUpdated is emitted for it.
+
+// aliasDefinition stores the flags and arguments an alias was loaded with,
+// before they are merged with the flags and arguments of the aliases it extends,
+// as well as the resolved copy of the alias that is in the trie, if any.
+type aliasDefinition struct {
+	alias     *alias.CommandAlias
+	flags     map[string]string
+	arguments []string
+	resolved  *alias.CommandAlias
+}
+
+// addAliasDefinition registers alias_ with the repository.
+// It must be called with r.mu held.
+func (r *Repository) addAliasDefinition(alias_ *alias.CommandAlias) {
+	key := pathKey(claycmds.CommandPath(alias_))
+	if definition, ok := r.aliases[key]; ok && definition.alias == alias_ {
+		// the alias is added again, keep its resolution
+		return
+	}
+
+	flags := map[string]string{}
+	for k, v := range alias_.Flags {
+		flags[k] = v
+	}
+	r.aliases[key] = &aliasDefinition{
+		alias:     alias_,
+		flags:     flags,
+		arguments: append([]string{}, alias_.Arguments...),
+	}
+}
+
+// removeAliasDefinition must be called with r.mu held.
+func (r *Repository) removeAliasDefinition(path []string) {
+	key := pathKey(path)
+	delete(r.aliases, key)
+	delete(r.pending, key)
+	delete(r.aliasErrors, key)
+}
+
+// aliasTarget returns the path of the command or alias that alias_ aliases.
+// It must be called with r.mu held.
+func (r *Repository) aliasTarget(alias_ *alias.CommandAlias) []string {
+	parents := alias_.Parents
+	if len(parents) == 0 || parents[len(parents)-1] != alias_.AliasFor {
+		sibling := append(append([]string{}, parents...), alias_.AliasFor)
+		if _, ok := r.aliases[pathKey(sibling)]; ok {
+			return sibling
+		}
+	}
+	return parents
+}
+
+// resolveChain follows the aliases extended by alias_ until it reaches a command.
+// It returns the extended aliases, closest first, and the aliased command, which is nil
+// if the chain is broken. An error is returned if the chain contains a cycle.
+// It must be called with r.mu held.
+func (r *Repository) resolveChain(alias_ *alias.CommandAlias) ([]*aliasDefinition, cmds.Command, error) {
+	start := pathKey(claycmds.CommandPath(alias_))
+	visited := map[string]bool{start: true}
+	cycle := []string{start}
+	chain := []*aliasDefinition{}
+
+	current := alias_
+	for {
+		target := r.aliasTarget(current)
+		key := pathKey(target)
+		cycle = append(cycle, key)
+		if visited[key] {
+			return nil, nil, errors.Errorf("alias cycle: %s", strings.Join(cycle, " -> "))
+		}
+		visited[key] = true
+
+		if definition, ok := r.aliases[key]; ok {
+			chain = append(chain, definition)
+			current = definition.alias
+			continue
+		}
+
+		command, ok := r.Root.FindCommand(target)
+		if !ok {
+			return chain, nil, nil
+		}
+		return chain, command, nil
+	}
+}
+
+type aliasResolution struct {
+	key     string
+	alias   *alias.CommandAlias
+	chain   []*aliasDefinition
+	command cmds.Command
+	err     error
+}
+
+// refreshAliases resolves every alias against the current content of the repository,
+// inserting the aliases whose chain leads to a command and moving the others to pending.
+// It must be called with r.mu held, after every change to the trie.
+func (r *Repository) refreshAliases() []RepositoryEvent {
+	keys := []string{}
+	for key := range r.aliases {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	resolved := []*aliasResolution{}
+	unresolved := []*aliasResolution{}
+	for _, key := range keys {
+		alias_ := r.aliases[key].alias
+		chain, command, err := r.resolveChain(alias_)
+		resolution := &aliasResolution{key: key, alias: alias_, chain: chain, command: command, err: err}
+		if command != nil {
+			resolved = append(resolved, resolution)
+		} else {
+			unresolved = append(unresolved, resolution)
+		}
+	}
+	// the aliases a chain extends need to be resolved first, since inserting an alias
+	// into the trie requires its description
+	sort.SliceStable(resolved, func(i, j int) bool {
+		return len(resolved[i].chain) < len(resolved[j].chain)
+	})
+
+	events := []RepositoryEvent{}
+	// unresolved aliases are removed from the trie first, so that the aliases
+	// extending them are never left in the trie without a description
+	for _, resolution := range unresolved {
+		events = append(events, r.unresolveAlias(resolution)...)
+	}
+	for _, resolution := range resolved {
+		events = append(events, r.resolveAlias(resolution)...)
+	}
+
+	return events
+}
+
+// unresolveAlias must be called with r.mu held.
+func (r *Repository) unresolveAlias(resolution *aliasResolution) []RepositoryEvent {
+	alias_ := resolution.alias
+	definition := r.aliases[resolution.key]
+	events := []RepositoryEvent{}
+
+	// a previous version of the alias was resolved, the copy in the trie is left untouched
+	// since it might still be used by the callers it was returned to
+	if definition.resolved != nil {
+		path := claycmds.CommandPath(alias_)
+		if previous, ok := r.Root.FindCommand(path); ok && previous == definition.resolved {
+			r.Root.RemoveCommand(path)
+			events = append(events, RepositoryEvent{Type: EventRemoved, Command: previous})
+		}
+		definition.resolved = nil
+	}
+
+	if r.pending[resolution.key] != alias_ {
+		log.Debug().Msgf("alias %s (prefix: %v) for %s not found, keeping it pending",
+			alias_.Name, alias_.Parents, alias_.AliasFor)
+		r.pending[resolution.key] = alias_
+		r.indexCommand(alias_)
+		events = append(events, RepositoryEvent{Type: EventAliasPending, Command: alias_})
+	}
+
+	if resolution.err == nil {
+		delete(r.aliasErrors, resolution.key)
+	} else if r.aliasErrors[resolution.key] == nil {
+		log.Warn().Err(resolution.err).Str("source", alias_.Source).Msgf("could not resolve alias %s", alias_.Name)
+		r.aliasErrors[resolution.key] = resolution.err
+		events = append(events, RepositoryEvent{
+			Type:    EventLoadError,
+			Command: alias_,
+			Source:  alias_.Source,
+			Error:   resolution.err,
+		})
+	}
+
+	return events
+}
+
+// resolveAlias inserts a resolved copy of the alias into the trie, unless the copy that is
+// already in the trie is up to date.
+// It must be called with r.mu held.
+func (r *Repository) resolveAlias(resolution *aliasResolution) []RepositoryEvent {
+	definition := r.aliases[resolution.key]
+	delete(r.aliasErrors, resolution.key)
+
+	// the aliases of the chain have been resolved before, so the closest one is in the trie
+	var aliasedCommand cmds.Command = resolution.command
+	if len(resolution.chain) > 0 {
+		aliasedCommand = resolution.chain[0].resolved
+	}
+
+	// merge the flags from the alias closest to the command up to alias_,
+	// and use the arguments of the closest alias that defines any
+	flags := map[string]string{}
+	arguments := definition.arguments
+	for i := len(resolution.chain) - 1; i >= 0; i-- {
+		for k, v := range resolution.chain[i].flags {
+			flags[k] = v
+		}
+	}
+	for k, v := range definition.flags {
+		flags[k] = v
+	}
+	for _, extended := range resolution.chain {
+		if len(arguments) > 0 {
+			break
+		}
+		arguments = extended.arguments
+	}
+
+	path := claycmds.CommandPath(definition.alias)
+	current, inTrie := r.Root.FindCommand(path)
+	if previous := definition.resolved; previous != nil && inTrie && current == previous &&
+		previous.AliasedCommand == aliasedCommand &&
+		reflect.DeepEqual(previous.Flags, flags) &&
+		reflect.DeepEqual(previous.Arguments, append([]string{}, arguments...)) {
+		return []RepositoryEvent{}
+	}
+
+	resolved := *definition.alias
+	resolved.AliasedCommand = aliasedCommand
+	resolved.Flags = flags
+	resolved.Arguments = append([]string{}, arguments...)
+	definition.resolved = &resolved
+
+	events := []RepositoryEvent{}
+	if _, isPending := r.pending[resolution.key]; isPending || !inTrie {
+		delete(r.pending, resolution.key)
+		events = append(events, RepositoryEvent{Type: EventAliasResolved, Command: &resolved})
+	}
+	events = append(events, r.insertEvent(resolved.Parents, resolved.Name, &resolved))
+	r.Root.InsertCommand(resolved.Parents, &resolved)
+	r.indexCommand(&resolved)
+
+	return events
+}
+
+// removePending drops the aliases at or below prefix that are not in the trie.
+// It must be called with r.mu held.
+func (r *Repository) removePending(prefix []string) {
+	for _, alias_ := range r.pending {
+		path := claycmds.CommandPath(alias_)
+		if len(path) < len(prefix) || pathKey(path[:len(prefix)]) != pathKey(prefix) {
+			continue
+		}
+		r.removeAliasDefinition(path)
+		r.unindexCommand(alias_)
+	}
+}
+
+func (r *Repository) pendingKeys() []string {
+	keys := []string{}
+	for key := range r.pending {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// PendingAliases returns the aliases whose aliased command is not in the repository,
+// sorted by path.
+func (r *Repository) PendingAliases() []*alias.CommandAlias {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ret := []*alias.CommandAlias{}
+	for _, key := range r.pendingKeys() {
+		ret = append(ret, r.pending[key])
+	}
+	return ret
+}
+
+// AliasErrors returns the errors that prevent aliases from being resolved, such as
+// alias cycles, keyed by the full path of the alias.
+func (r *Repository) AliasErrors() map[string]error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ret := map[string]error{}
+	for key, err := range r.aliasErrors {
+		ret[key] = err
+	}
+	return ret
+}
+
+// isAlias returns true if path is the path of an alias, pending or not.
+// It must be called with r.mu held.
+func (r *Repository) isAlias(path []string) bool {
+	_, ok := r.aliases[pathKey(path)]
+	return ok
+}
@@ -0,0 +1,318 @@
+package fs
+
+import (
+	"context"
+	claycmds "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func getPendingNames(r *Repository) []string {
+	ret := []string{}
+	for _, a := range r.PendingAliases() {
+		ret = append(ret, pathKey(claycmds.CommandPath(a)))
+	}
+	return ret
+}
+
+// findAlias returns the resolved alias at path.
+func findAlias(t *testing.T, r *Repository, path ...string) *alias.CommandAlias {
+	c, ok := r.FindCommand(path)
+	require.True(t, ok, path)
+	return c.(*alias.CommandAlias)
+}
+
+func TestAliasAddedBeforeCommand(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := NewRepository()
+	events := r.Subscribe(ctx)
+
+	a := &alias.CommandAlias{
+		Name:     "active",
+		AliasFor: "users",
+		Parents:  []string{"ops", "users"},
+	}
+	r.Add(a)
+	assert.Equal(t, []string{"ops users active"}, getPendingNames(r))
+	_, ok := r.FindCommand([]string{"ops", "users", "active"})
+	assert.False(t, ok)
+
+	users := MakeTestCommand([]string{"ops"}, "users")
+	r.Add(users)
+	assert.Empty(t, r.PendingAliases())
+	c, ok := r.FindCommand([]string{"ops", "users", "active"})
+	require.True(t, ok)
+	assert.Equal(t, users, c.(*alias.CommandAlias).AliasedCommand)
+
+	assert.Equal(t, []EventType{
+		EventAliasPending,
+		EventAdded,
+		EventAliasResolved,
+		EventAdded,
+	}, getEventTypes(receiveEvents(t, events, 4)))
+}
+
+func TestAliasBackToPendingWhenCommandRemoved(t *testing.T) {
+	dir := t.TempDir()
+	users := writeTestFile(t, dir, "ops/users.yaml", "name: users\nshort: List users\n")
+
+	r := newTestRepository(t, dir)
+	a := &alias.CommandAlias{
+		Name:     "active",
+		AliasFor: "users",
+		Parents:  []string{"ops", "users"},
+		Source:   filepath.Join(dir, "ops/users/active.yaml"),
+	}
+	r.Add(a)
+	assert.Equal(t, []string{"ops users", "ops users active"}, getSortedFullNames(r))
+
+	r.RemoveFile(users)
+	assert.Empty(t, getSortedFullNames(r))
+	assert.Equal(t, []string{"ops users active"}, getPendingNames(r))
+	assert.Nil(t, r.PendingAliases()[0].AliasedCommand)
+
+	// the alias is resolved again when the command comes back
+	require.NoError(t, r.LoadFile(users))
+	assert.Equal(t, []string{"ops users", "ops users active"}, getSortedFullNames(r))
+	assert.Empty(t, r.PendingAliases())
+	assert.NotNil(t, findAlias(t, r, "ops", "users", "active").AliasedCommand)
+
+	// removing the alias file drops the pending alias
+	r.RemoveFile(users)
+	r.RemoveFile(a.Source)
+	assert.Empty(t, r.PendingAliases())
+}
+
+func TestAliasRebindOnCommandUpdate(t *testing.T) {
+	r := NewRepository()
+	r.Add(MakeTestCommand([]string{"ops"}, "users"))
+	a := &alias.CommandAlias{
+		Name:     "active",
+		AliasFor: "users",
+		Parents:  []string{"ops", "users"},
+	}
+	r.Add(a)
+
+	resolved := findAlias(t, r, "ops", "users", "active")
+
+	updated := MakeTestCommand([]string{"ops"}, "users")
+	r.Add(updated)
+	assert.Same(t, updated, findAlias(t, r, "ops", "users", "active").AliasedCommand)
+	// the alias returned before the update is left untouched
+	assert.NotSame(t, updated, resolved.AliasedCommand)
+	assert.Nil(t, a.AliasedCommand)
+}
+
+func TestRemovePrefixDropsPendingAliases(t *testing.T) {
+	r := NewRepository()
+	r.Add(&alias.CommandAlias{
+		Name:     "active",
+		AliasFor: "users",
+		Parents:  []string{"ops", "users"},
+	})
+	require.Len(t, r.PendingAliases(), 1)
+
+	r.Remove([]string{"ops"})
+	assert.Empty(t, r.PendingAliases())
+}
+
+func TestAliasChain(t *testing.T) {
+	r := NewRepository()
+	users := MakeTestCommand([]string{"ops"}, "users")
+	active := &alias.CommandAlias{
+		Name:     "active",
+		AliasFor: "users",
+		Flags:    map[string]string{"active": "true", "limit": "10"},
+		Parents:  []string{"ops", "users"},
+	}
+	mine := &alias.CommandAlias{
+		Name:      "mine",
+		AliasFor:  "active",
+		Flags:     map[string]string{"owner": "me", "limit": "5"},
+		Arguments: []string{"foo"},
+		Parents:   []string{"ops", "users"},
+	}
+	// add the aliases in reverse order, the chain is resolved once the command is added
+	r.Add(mine, active)
+	assert.Equal(t, []string{"ops users active", "ops users mine"}, getPendingNames(r))
+
+	r.Add(users)
+	assert.Empty(t, r.PendingAliases())
+	assert.Equal(t, []string{"ops users", "ops users active", "ops users mine"}, getSortedFullNames(r))
+	resolvedActive := findAlias(t, r, "ops", "users", "active")
+	resolvedMine := findAlias(t, r, "ops", "users", "mine")
+	assert.Same(t, resolvedActive, resolvedMine.AliasedCommand)
+	assert.Same(t, users, resolvedActive.AliasedCommand)
+	assert.Equal(t, map[string]string{"active": "true", "limit": "5", "owner": "me"}, resolvedMine.Flags)
+	assert.Equal(t, []string{"foo"}, resolvedMine.Arguments)
+	// the added aliases are not modified
+	assert.Equal(t, map[string]string{"owner": "me", "limit": "5"}, mine.Flags)
+
+	// updating the base alias updates the merged flags
+	r.Add(&alias.CommandAlias{
+		Name:     "active",
+		AliasFor: "users",
+		Flags:    map[string]string{"active": "false"},
+		Parents:  []string{"ops", "users"},
+	})
+	assert.Equal(t, map[string]string{"active": "false", "limit": "5", "owner": "me"},
+		findAlias(t, r, "ops", "users", "mine").Flags)
+	assert.Equal(t, map[string]string{"active": "true", "limit": "5", "owner": "me"}, resolvedMine.Flags)
+
+	// removing the command removes the aliases stored below it
+	r.Remove([]string{"ops", "users"})
+	assert.Empty(t, getSortedFullNames(r))
+	assert.Empty(t, r.PendingAliases())
+
+	// and they don't come back with the command
+	r.Add(users)
+	assert.Equal(t, []string{"ops users"}, getSortedFullNames(r))
+}
+
+func TestRemoveCommandSendsAliasesOutsidePrefixToPending(t *testing.T) {
+	r := NewRepository()
+	r.Add(MakeTestCommand([]string{"ops"}, "users"))
+	a := &alias.CommandAlias{Name: "active", AliasFor: "users", Parents: []string{"ops", "users"}}
+	r.Add(a)
+
+	// removing the command alone leaves the alias in place, pending
+	r.mu.Lock()
+	r.removeCommand([]string{"ops", "users"})
+	r.refreshAliases()
+	r.mu.Unlock()
+	assert.Equal(t, []string{"ops users active"}, getPendingNames(r))
+	assert.Nil(t, r.PendingAliases()[0].AliasedCommand)
+}
+
+func TestAliasChainPendingWhenBaseAliasRemoved(t *testing.T) {
+	r := NewRepository()
+	r.Add(MakeTestCommand([]string{"ops"}, "users"))
+	r.Add(&alias.CommandAlias{Name: "active", AliasFor: "users", Parents: []string{"ops", "users"}})
+	r.Add(&alias.CommandAlias{Name: "mine", AliasFor: "active", Parents: []string{"ops", "users"}})
+	require.Equal(t, []string{"ops users", "ops users active", "ops users mine"}, getSortedFullNames(r))
+
+	r.mu.Lock()
+	r.removeCommand([]string{"ops", "users", "active"})
+	r.refreshAliases()
+	r.mu.Unlock()
+
+	// mine now aliases the users command directly, since there is no alias named active anymore
+	assert.Equal(t, []string{"ops users", "ops users mine"}, getSortedFullNames(r))
+}
+
+func TestAliasCycle(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := NewRepository()
+	r.Add(MakeTestCommand([]string{"ops"}, "users"))
+	events := r.Subscribe(ctx)
+
+	r.Add(
+		&alias.CommandAlias{Name: "a", AliasFor: "b", Parents: []string{"ops", "users"}, Source: "a.yaml"},
+		&alias.CommandAlias{Name: "b", AliasFor: "a", Parents: []string{"ops", "users"}, Source: "b.yaml"},
+	)
+	assert.Equal(t, []string{"ops users a", "ops users b"}, getPendingNames(r))
+	assert.Equal(t, []string{"ops users"}, getSortedFullNames(r))
+
+	errors_ := r.AliasErrors()
+	require.Len(t, errors_, 2)
+	assert.EqualError(t, errors_["ops users a"], "alias cycle: ops users a -> ops users b -> ops users a")
+
+	received := receiveEvents(t, events, 4)
+	assert.Equal(t, []EventType{
+		EventAliasPending,
+		EventLoadError,
+		EventAliasPending,
+		EventLoadError,
+	}, getEventTypes(received))
+	assert.Equal(t, "a.yaml", received[1].Source)
+
+	// breaking the cycle resolves both aliases
+	r.Add(&alias.CommandAlias{Name: "b", AliasFor: "users", Parents: []string{"ops", "users"}})
+	assert.Empty(t, r.PendingAliases())
+	assert.Empty(t, r.AliasErrors())
+	assert.Equal(t, []string{"ops users", "ops users a", "ops users b"}, getSortedFullNames(r))
+}
+
+func TestRemoveCommandOfAliasChain(t *testing.T) {
+	dir := t.TempDir()
+	users := writeTestFile(t, dir, "ops/users.yaml", "name: users\nshort: List users\n")
+
+	r := newTestRepository(t, dir)
+	r.Add(
+		&alias.CommandAlias{Name: "active", AliasFor: "users", Parents: []string{"ops", "users"},
+			Source: filepath.Join(dir, "ops/users/active.yaml")},
+		&alias.CommandAlias{Name: "mine", AliasFor: "active", Parents: []string{"ops", "users"},
+			Source: filepath.Join(dir, "ops/users/mine.yaml")},
+	)
+	require.Equal(t, []string{"ops users", "ops users active", "ops users mine"}, getSortedFullNames(r))
+
+	r.RemoveFile(users)
+	assert.Empty(t, getSortedFullNames(r))
+	assert.Equal(t, []string{"ops users active", "ops users mine"}, getPendingNames(r))
+
+	require.NoError(t, r.LoadFile(users))
+	assert.Equal(t, []string{"ops users", "ops users active", "ops users mine"}, getSortedFullNames(r))
+}
+
+func TestReplacingCommandUpdatesAliases(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := NewRepository()
+	r.Add(MakeTestCommand([]string{"ops"}, "users"))
+	r.Add(
+		&alias.CommandAlias{Name: "active", AliasFor: "users", Parents: []string{"ops", "users"}},
+		&alias.CommandAlias{Name: "mine", AliasFor: "active", Parents: []string{"ops", "users"}},
+	)
+	events := r.Subscribe(ctx)
+
+	updated := MakeTestCommand([]string{"ops"}, "users")
+	r.Add(updated)
+	received := receiveEvents(t, events, 3)
+	assert.Equal(t, []EventType{EventUpdated, EventUpdated, EventUpdated}, getEventTypes(received))
+	assert.Same(t, updated, received[0].Command)
+	assert.Same(t, updated, received[1].Command.(*alias.CommandAlias).AliasedCommand)
+	assert.Same(t, received[1].Command, received[2].Command.(*alias.CommandAlias).AliasedCommand)
+
+	// adding an unrelated command doesn't touch the aliases
+	r.Add(MakeTestCommand([]string{"ops"}, "groups"))
+	received = receiveEvents(t, events, 1)
+	assert.Equal(t, []EventType{EventAdded}, getEventTypes(received))
+}
+
+func TestResolvedAliasesAreNotModified(t *testing.T) {
+	r := NewRepository()
+	r.Add(MakeTestCommand([]string{"ops"}, "users"))
+	r.Add(&alias.CommandAlias{
+		Name:     "active",
+		AliasFor: "users",
+		Flags:    map[string]string{"active": "true"},
+		Parents:  []string{"ops", "users"},
+	})
+
+	// reading the collected alias races with the changes to the repository
+	// when run with -race if the alias is modified in place
+	collected := findAlias(t, r, "ops", "users", "active")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_ = collected.AliasedCommand
+			_ = collected.Flags["active"]
+			_ = collected.Arguments
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		r.Add(MakeTestCommand([]string{"ops"}, "users"))
+	}
+	<-done
+
+	assert.Equal(t, map[string]string{"active": "true"}, findAlias(t, r, "ops", "users", "active").Flags)
+}
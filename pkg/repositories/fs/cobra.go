@@ -0,0 +1,371 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	claycmds "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cli"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"sort"
+	"sync"
+)
+
+// A CobraTree mirrors the commands of a repository as cobra commands below a root command.
+//
+// Every node of the trie becomes a cobra command: commands and aliases are built with the
+// CobraCommandBuilder, and group nodes become parent commands using the metadata of their
+// group file. The tree follows the changes to the repository, so that commands added or
+// removed by Repository.Watch appear or disappear without a restart.
+//
+// Cobra commands are not safe for concurrent use. Applications executing commands while
+// the repository is being watched, such as shells and REPLs, should hold the tree with
+// Lock while executing a command line.
+
+// CobraCommandBuilder builds the cobra command of a repository command,
+// for example cli.BuildCobraCommandFromCommand or sql.BuildCobraCommandWithSqletonMiddlewares.
+type CobraCommandBuilder func(command cmds.Command, options ...cli.CobraParserOption) (*cobra.Command, error)
+
+type CobraTree struct {
+	repository    *Repository
+	root          *cobra.Command
+	builder       CobraCommandBuilder
+	parserOptions []cli.CobraParserOption
+
+	mu sync.Mutex
+	// commands maps the path of every cobra command created by the tree to that command.
+	// groups records which of them are group nodes, that are removed once they are empty.
+	commands map[string]*cobra.Command
+	groups   map[string]bool
+}
+
+type CobraTreeOption func(*CobraTree)
+
+// WithCobraCommandBuilder sets the builder used for commands and aliases,
+// cli.BuildCobraCommandFromCommand by default.
+func WithCobraCommandBuilder(builder CobraCommandBuilder) CobraTreeOption {
+	return func(t *CobraTree) {
+		t.builder = builder
+	}
+}
+
+// WithCobraParserOptions sets the options passed to the builder.
+func WithCobraParserOptions(options ...cli.CobraParserOption) CobraTreeOption {
+	return func(t *CobraTree) {
+		t.parserOptions = append(t.parserOptions, options...)
+	}
+}
+
+// NewCobraTree builds the cobra commands of the repository below root, and updates them
+// as the repository changes until ctx is cancelled.
+func NewCobraTree(
+	ctx context.Context,
+	repository *Repository,
+	root *cobra.Command,
+	options ...CobraTreeOption,
+) (*CobraTree, error) {
+	t := &CobraTree{
+		repository: repository,
+		root:       root,
+		builder:    cli.BuildCobraCommandFromCommand,
+		commands:   map[string]*cobra.Command{},
+		groups:     map[string]bool{},
+	}
+	for _, option := range options {
+		option(t)
+	}
+
+	// subscribe before building, so that no change is lost in between.
+	// Changes that are already part of the tree are applied again, which is harmless.
+	events := repository.Subscribe(ctx)
+
+	t.mu.Lock()
+	err := t.build()
+	t.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		for event := range events {
+			t.mu.Lock()
+			t.apply(event)
+			t.mu.Unlock()
+		}
+	}()
+
+	return t, nil
+}
+
+// Lock prevents the tree from being updated until Unlock is called.
+func (t *CobraTree) Lock() {
+	t.mu.Lock()
+}
+
+func (t *CobraTree) Unlock() {
+	t.mu.Unlock()
+}
+
+// build adds the commands of the whole trie. It must be called with t.mu held.
+func (t *CobraTree) build() error {
+	commands := []cmds.Command{}
+
+	t.repository.mu.RLock()
+	var walk func(node *TrieNode, path []string)
+	walk = func(node *TrieNode, path []string) {
+		// commands are added before the nodes below them, which contain their aliases
+		nodeCommands := append([]cmds.Command{}, node.Commands...)
+		sort.Slice(nodeCommands, func(i, j int) bool {
+			return nodeCommands[i].Description().Name < nodeCommands[j].Description().Name
+		})
+		commands = append(commands, nodeCommands...)
+		for _, name := range node.SortedChildNames() {
+			walk(node.Children[name], append(append([]string{}, path...), name))
+		}
+	}
+	walk(t.repository.Root, []string{})
+	t.repository.mu.RUnlock()
+
+	// group commands are created as needed, so that empty nodes don't show up
+	for _, command := range commands {
+		err := t.addCommand(claycmds.CommandPath(command), command)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// apply updates the tree after a change to the repository. It must be called with t.mu held.
+func (t *CobraTree) apply(event RepositoryEvent) {
+	switch event.Type {
+	case EventAdded, EventUpdated:
+		path := claycmds.CommandPath(event.Command)
+		err := t.addCommand(path, event.Command)
+		if err != nil {
+			log.Warn().Err(err).Str("command", pathKey(path)).Str("source", claycmds.CommandSource(event.Command)).
+				Msg("Could not build cobra command")
+		}
+	case EventRemoved:
+		t.removeCommand(claycmds.CommandPath(event.Command))
+	case EventGroupUpdated:
+		key := pathKey(event.Group)
+		if cmd, ok := t.commands[key]; ok && t.groups[key] {
+			group, _ := t.repository.GetGroup(event.Group)
+			t.applyGroup(cmd, event.Group, group)
+		}
+	case EventAliasResolved, EventAliasPending, EventLoadError:
+		// resolved aliases are also sent as added, pending aliases as removed
+	}
+}
+
+// addCommand adds or replaces the cobra command at path. It must be called with t.mu held.
+func (t *CobraTree) addCommand(path []string, command cmds.Command) error {
+	var cobraCommand *cobra.Command
+	var err error
+	if alias_, ok := command.(*alias.CommandAlias); ok {
+		cobraCommand, err = t.buildAlias(alias_)
+	} else {
+		cobraCommand, err = t.builder(command, t.parserOptions...)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "could not build cobra command %s", pathKey(path))
+	}
+
+	parent := t.findOrCreateParent(path[:len(path)-1])
+	key := pathKey(path)
+	if previous, ok := t.commands[key]; ok {
+		// keep the commands nested below the previous command, for example its aliases
+		for _, child := range previous.Commands() {
+			previous.RemoveCommand(child)
+			cobraCommand.AddCommand(child)
+		}
+		parent.RemoveCommand(previous)
+	}
+	parent.AddCommand(cobraCommand)
+	t.commands[key] = cobraCommand
+	delete(t.groups, key)
+
+	return nil
+}
+
+// removeCommand must be called with t.mu held.
+func (t *CobraTree) removeCommand(path []string) {
+	key := pathKey(path)
+	cmd, ok := t.commands[key]
+	if !ok || t.groups[key] {
+		return
+	}
+	parent := cmd.Parent()
+	parent.RemoveCommand(cmd)
+	delete(t.commands, key)
+
+	// the commands nested below the removed command are kept in a group
+	if cmd.HasSubCommands() {
+		group := t.findOrCreateParent(path)
+		for _, child := range cmd.Commands() {
+			cmd.RemoveCommand(child)
+			group.AddCommand(child)
+		}
+		return
+	}
+
+	// remove the groups that are now empty
+	for len(path) > 1 {
+		path = path[:len(path)-1]
+		key = pathKey(path)
+		if !t.groups[key] || t.commands[key].HasSubCommands() {
+			break
+		}
+		group := t.commands[key]
+		group.Parent().RemoveCommand(group)
+		delete(t.commands, key)
+		delete(t.groups, key)
+	}
+}
+
+// findOrCreateParent returns the cobra command at path, creating group commands as needed.
+// Existing commands of the root command that were not created by the tree are reused.
+// It must be called with t.mu held.
+func (t *CobraTree) findOrCreateParent(path []string) *cobra.Command {
+	parent := t.root
+	for i, name := range path {
+		key := pathKey(path[:i+1])
+		if cmd, ok := t.commands[key]; ok {
+			parent = cmd
+			continue
+		}
+
+		var cmd *cobra.Command
+		for _, c := range parent.Commands() {
+			if c.Name() == name {
+				cmd = c
+				break
+			}
+		}
+		if cmd == nil {
+			cmd = &cobra.Command{Use: name}
+			t.commands[key] = cmd
+			t.groups[key] = true
+			group, _ := t.repository.GetGroup(path[:i+1])
+			t.applyGroup(cmd, path[:i+1], group)
+			parent.AddCommand(cmd)
+		}
+		parent = cmd
+	}
+	return parent
+}
+
+// applyGroup sets the help of the group command cmd from its metadata.
+// Commands that are not group commands created by the tree are left untouched. It must be called with t.mu held.
+func (t *CobraTree) applyGroup(cmd *cobra.Command, path []string, group *GroupMetadata) {
+	if !t.groups[pathKey(path)] {
+		return
+	}
+	name := path[len(path)-1]
+	if group == nil {
+		group = &GroupMetadata{}
+	}
+	cmd.Short = group.Short
+	if cmd.Short == "" {
+		cmd.Short = fmt.Sprintf("All commands for %s", name)
+	}
+	cmd.Long = group.Long
+	cmd.Hidden = group.Hidden
+}
+
+// buildAlias builds the command at the end of the alias chain with the builder,
+// and applies the flags and arguments of the alias to it, like cli.BuildCobraCommandAlias.
+// The aliases of the repository are not modified once resolved, and the repository sends an
+// EventUpdated for every alias whose chain changed, which rebuilds its cobra command.
+// It must be called with t.mu held.
+func (t *CobraTree) buildAlias(alias_ *alias.CommandAlias) (*cobra.Command, error) {
+	command := alias_.AliasedCommand
+	flags := map[string]string{}
+	for k, v := range alias_.Flags {
+		flags[k] = v
+	}
+	arguments := append([]string{}, alias_.Arguments...)
+	for {
+		aliased, ok := command.(*alias.CommandAlias)
+		if !ok {
+			break
+		}
+		command = aliased.AliasedCommand
+	}
+	if command == nil {
+		return nil, errors.Errorf("alias %s is not resolved", alias_.Name)
+	}
+
+	cmd, err := t.builder(command, t.parserOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	description := command.Description()
+	cmd.Use = alias_.Name
+	cmd.Short = fmt.Sprintf("Alias for %s", alias_.AliasFor)
+
+	minArgs := 0
+	argumentDefinitions := description.GetDefaultArguments()
+	provided, err := argumentDefinitions.GatherArguments(
+		arguments, true, true,
+		parameters.WithParseStepSource("cobra-alias"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	argumentDefinitions.ForEach(func(argDef *parameters.ParameterDefinition) {
+		_, ok := provided.Get(argDef.Name)
+		if argDef.Required && !ok {
+			minArgs++
+		}
+	})
+	cmd.Args = cobra.MinimumNArgs(minArgs)
+
+	// applyAlias sets the flags of the alias that were not given on the command line,
+	// and returns the arguments to run the command with
+	applyAlias := func(cmd *cobra.Command, args []string) ([]string, error) {
+		for k, v := range flags {
+			if !cmd.Flags().Changed(k) {
+				err := cmd.Flags().Set(k, v)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		if len(args) == 0 {
+			args = arguments
+		}
+		return args, nil
+	}
+
+	// cobra runs RunE instead of Run if both are set
+	switch {
+	case cmd.RunE != nil:
+		origRunE := cmd.RunE
+		cmd.RunE = func(cmd *cobra.Command, args []string) error {
+			args, err := applyAlias(cmd, args)
+			if err != nil {
+				return err
+			}
+			return origRunE(cmd, args)
+		}
+	case cmd.Run != nil:
+		origRun := cmd.Run
+		cmd.Run = func(cmd *cobra.Command, args []string) {
+			args, err := applyAlias(cmd, args)
+			cobra.CheckErr(err)
+			origRun(cmd, args)
+		}
+	default:
+		return nil, errors.Errorf("command %s aliased by %s can't be run", description.Name, alias_.Name)
+	}
+
+	return cmd, nil
+}
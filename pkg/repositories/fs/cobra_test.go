@@ -0,0 +1,202 @@
+package fs
+
+import (
+	"context"
+	"github.com/go-go-golems/glazed/pkg/cli"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func buildTestCobraCommand(command cmds.Command, options ...cli.CobraParserOption) (*cobra.Command, error) {
+	description := command.Description()
+	return &cobra.Command{
+		Use:   description.Name,
+		Short: "command " + description.Name,
+		Run:   func(cmd *cobra.Command, args []string) {},
+	}, nil
+}
+
+// getCobraPaths returns the paths of all the commands below root.
+func getCobraPaths(tree *CobraTree, root *cobra.Command) []string {
+	tree.Lock()
+	defer tree.Unlock()
+
+	ret := []string{}
+	var walk func(cmd *cobra.Command, path []string)
+	walk = func(cmd *cobra.Command, path []string) {
+		for _, child := range cmd.Commands() {
+			childPath := append(append([]string{}, path...), child.Name())
+			ret = append(ret, strings.Join(childPath, " "))
+			walk(child, childPath)
+		}
+	}
+	walk(root, []string{})
+	sort.Strings(ret)
+	return ret
+}
+
+func TestCobraTree(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir := t.TempDir()
+	writeTestFile(t, dir, "ops/users.yaml", "name: users\n")
+	writeTestFile(t, dir, "ops/_group.yaml", "short: Operations\nhidden: true\n")
+	writeTestFile(t, dir, "ops/users/mine.yaml", "name: mine\naliasFor: users\n")
+	writeTestFile(t, dir, "db/ls.yaml", "name: ls\n")
+
+	r := newTestRepository(t, dir)
+	root := &cobra.Command{Use: "app"}
+	tree, err := NewCobraTree(ctx, r, root, WithCobraCommandBuilder(buildTestCobraCommand))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"db", "db ls", "ops", "ops users", "ops users mine"}, getCobraPaths(tree, root))
+
+	ops, _, err := root.Find([]string{"ops"})
+	require.NoError(t, err)
+	assert.Equal(t, "Operations", ops.Short)
+	assert.True(t, ops.Hidden)
+	db, _, err := root.Find([]string{"db"})
+	require.NoError(t, err)
+	assert.Equal(t, "All commands for db", db.Short)
+	mine, _, err := root.Find([]string{"ops", "users", "mine"})
+	require.NoError(t, err)
+	assert.Equal(t, "Alias for users", mine.Short)
+
+	r.Add(MakeTestCommand([]string{"db", "tables"}, "describe"))
+	require.Eventually(t, func() bool {
+		paths := getCobraPaths(tree, root)
+		return len(paths) == 7 && paths[2] == "db tables"
+	}, time.Second, 10*time.Millisecond)
+
+	// empty groups are removed along with their last command
+	r.Remove([]string{"db"})
+	require.Eventually(t, func() bool {
+		return strings.Join(getCobraPaths(tree, root), ",") == "ops,ops users,ops users mine"
+	}, time.Second, 10*time.Millisecond)
+
+	// the alias is kept when its command is replaced
+	r.Add(MakeTestCommand([]string{"ops"}, "users"))
+	require.Eventually(t, func() bool {
+		tree.Lock()
+		defer tree.Unlock()
+		users, _, _ := root.Find([]string{"ops", "users"})
+		return users != nil && users.Short == "command users" && users.HasSubCommands()
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"ops", "ops users", "ops users mine"}, getCobraPaths(tree, root))
+}
+
+func TestCobraTreeRemovesAliasWithItsCommand(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := NewRepository()
+	r.Add(
+		MakeTestCommand([]string{"ops"}, "users"),
+		&alias.CommandAlias{
+			Name:     "mine",
+			AliasFor: "users",
+			Flags:    map[string]string{"owner": "me"},
+			Parents:  []string{"ops", "users"},
+		},
+	)
+	root := &cobra.Command{Use: "app"}
+	tree, err := NewCobraTree(ctx, r, root, WithCobraCommandBuilder(buildTestCobraCommand))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ops", "ops users", "ops users mine"}, getCobraPaths(tree, root))
+
+	r.Remove([]string{"ops", "users"})
+	require.Eventually(t, func() bool {
+		return len(getCobraPaths(tree, root)) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestCobraTreeRebuildsAliasesOfReplacedCommand(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var ran cmds.Command
+	builder := func(command cmds.Command, options ...cli.CobraParserOption) (*cobra.Command, error) {
+		return &cobra.Command{
+			Use: command.Description().Name,
+			Run: func(cmd *cobra.Command, args []string) {
+				ran = command
+			},
+		}, nil
+	}
+
+	r := NewRepository()
+	r.Add(
+		MakeTestCommand([]string{"ops"}, "users"),
+		&alias.CommandAlias{Name: "active", AliasFor: "users", Parents: []string{"ops", "users"}},
+		&alias.CommandAlias{Name: "mine", AliasFor: "active", Parents: []string{"ops", "users"}},
+	)
+	root := &cobra.Command{Use: "app"}
+	tree, err := NewCobraTree(ctx, r, root, WithCobraCommandBuilder(builder))
+	require.NoError(t, err)
+
+	updated := MakeTestCommand([]string{"ops"}, "users")
+	r.Add(updated)
+
+	// the aliases run the new command once they have been rebuilt
+	for _, name := range []string{"active", "mine"} {
+		require.Eventually(t, func() bool {
+			tree.Lock()
+			defer tree.Unlock()
+			ran = nil
+			root.SetArgs([]string{"ops", "users", name})
+			return root.Execute() == nil && ran == updated
+		}, time.Second, 10*time.Millisecond, name)
+	}
+}
+
+func TestCobraTreeAliasOfRunECommand(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	owner := ""
+	builder := func(command cmds.Command, options ...cli.CobraParserOption) (*cobra.Command, error) {
+		cmd := &cobra.Command{
+			Use: command.Description().Name,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				var err error
+				owner, err = cmd.Flags().GetString("owner")
+				return err
+			},
+		}
+		cmd.Flags().String("owner", "", "")
+		return cmd, nil
+	}
+
+	r := NewRepository()
+	r.Add(
+		MakeTestCommand([]string{"ops"}, "users"),
+		&alias.CommandAlias{
+			Name:     "mine",
+			AliasFor: "users",
+			Flags:    map[string]string{"owner": "me"},
+			Parents:  []string{"ops", "users"},
+		},
+	)
+	root := &cobra.Command{Use: "app"}
+	_, err := NewCobraTree(ctx, r, root, WithCobraCommandBuilder(builder))
+	require.NoError(t, err)
+
+	root.SetArgs([]string{"ops", "users", "mine"})
+	require.NoError(t, root.Execute())
+	assert.Equal(t, "me", owner)
+
+	// aliases of commands that can't be run are reported
+	noRun := func(command cmds.Command, options ...cli.CobraParserOption) (*cobra.Command, error) {
+		return &cobra.Command{Use: command.Description().Name}, nil
+	}
+	_, err = NewCobraTree(ctx, r, &cobra.Command{Use: "app"}, WithCobraCommandBuilder(noRun))
+	assert.Error(t, err)
+}
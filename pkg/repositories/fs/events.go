@@ -0,0 +1,145 @@
+package fs
+
+import (
+	"context"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"sync"
+)
+
+type EventType string
+
+const (
+	// EventAdded is sent when a command or alias is added at a new path.
+	EventAdded EventType = "added"
+	// EventUpdated is sent when a command or alias replaces an existing one.
+	EventUpdated EventType = "updated"
+	// EventRemoved is sent when a command or alias is removed from the repository.
+	EventRemoved EventType = "removed"
+	// EventAliasResolved is sent when an alias is bound to the command it aliases.
+	EventAliasResolved EventType = "alias-resolved"
+	// EventAliasPending is sent when an alias can't be resolved because the command it aliases
+	// is not in the repository (yet). The alias is resolved once the command is added.
+	EventAliasPending EventType = "alias-pending"
+	// EventLoadError is sent when a file could not be loaded.
+	EventLoadError EventType = "load-error"
+	// EventGroupUpdated is sent when a group file is loaded, changed or removed.
+	EventGroupUpdated EventType = "group-updated"
+)
+
+// RepositoryEvent describes a change to the repository.
+//
+// Command is set for all events except EventLoadError,
+// which sets Source to the file that failed to load and Error.
+// Load errors caused by an alias that can't be resolved also set Command.
+// EventGroupUpdated sets Source to the group file and Group to the path of the group,
+// see GetGroup for its current metadata.
+type RepositoryEvent struct {
+	Type    EventType
+	Command cmds.Command
+	Source  string
+	Error   error
+	Group   []string
+}
+
+// subscriberBufferSize is the size of the channel handed out to subscribers.
+// Events that don't fit into the channel are queued in the subscriber,
+// so that a slow subscriber never blocks the repository.
+const subscriberBufferSize = 16
+
+type subscriber struct {
+	mu     sync.Mutex
+	queue  []RepositoryEvent
+	notify chan struct{}
+	events chan RepositoryEvent
+}
+
+func newSubscriber() *subscriber {
+	return &subscriber{
+		queue:  []RepositoryEvent{},
+		notify: make(chan struct{}, 1),
+		events: make(chan RepositoryEvent, subscriberBufferSize),
+	}
+}
+
+// push queues the event without ever blocking.
+func (s *subscriber) push(events ...RepositoryEvent) {
+	s.mu.Lock()
+	s.queue = append(s.queue, events...)
+	s.mu.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *subscriber) pop() (RepositoryEvent, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 {
+		return RepositoryEvent{}, false
+	}
+	event := s.queue[0]
+	s.queue = s.queue[1:]
+	return event, true
+}
+
+// run delivers the queued events to the events channel until ctx is cancelled.
+func (s *subscriber) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.notify:
+		}
+
+		for {
+			event, ok := s.pop()
+			if !ok {
+				break
+			}
+			select {
+			case s.events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// Subscribe returns a channel on which all subsequent changes to the repository are delivered,
+// in the order they happened.
+//
+// Every subscriber gets its own queue, so a slow subscriber doesn't block
+// the repository or other subscribers. The channel is closed once ctx is cancelled.
+func (r *Repository) Subscribe(ctx context.Context) <-chan RepositoryEvent {
+	s := newSubscriber()
+
+	r.subscribersMu.Lock()
+	r.subscribers[s] = struct{}{}
+	r.subscribersMu.Unlock()
+
+	go func() {
+		s.run(ctx)
+
+		r.subscribersMu.Lock()
+		delete(r.subscribers, s)
+		r.subscribersMu.Unlock()
+
+		close(s.events)
+	}()
+
+	return s.events
+}
+
+func (r *Repository) publish(events ...RepositoryEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	r.subscribersMu.Lock()
+	defer r.subscribersMu.Unlock()
+	for s := range r.subscribers {
+		s.push(events...)
+	}
+}
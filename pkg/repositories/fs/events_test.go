@@ -0,0 +1,106 @@
+package fs
+
+import (
+	"context"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func receiveEvents(t *testing.T, events <-chan RepositoryEvent, n int) []RepositoryEvent {
+	ret := []RepositoryEvent{}
+	for i := 0; i < n; i++ {
+		select {
+		case event := <-events:
+			ret = append(ret, event)
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for event %d", i)
+		}
+	}
+	return ret
+}
+
+func getEventTypes(events []RepositoryEvent) []EventType {
+	ret := []EventType{}
+	for _, e := range events {
+		ret = append(ret, e.Type)
+	}
+	return ret
+}
+
+func TestSubscribeAddUpdateRemove(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := NewRepository()
+	events := r.Subscribe(ctx)
+
+	r.Add(MakeTestCommand([]string{"foo"}, "bar"))
+	r.Add(MakeTestCommand([]string{"foo"}, "bar"))
+	a := &alias.CommandAlias{
+		Name:     "baz",
+		AliasFor: "bar",
+		Parents:  []string{"foo", "bar"},
+	}
+	r.Add(a)
+	r.Remove([]string{"foo", "bar"})
+
+	received := receiveEvents(t, events, 6)
+	assert.Equal(t, []EventType{
+		EventAdded,
+		EventUpdated,
+		EventAliasResolved,
+		EventAdded,
+		EventRemoved,
+		EventRemoved,
+	}, getEventTypes(received))
+	assert.Equal(t, a.Name, received[2].Command.(*alias.CommandAlias).Name)
+}
+
+func TestSlowSubscriberDoesNotBlock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := NewRepository()
+	slow := r.Subscribe(ctx)
+	fast := r.Subscribe(ctx)
+
+	// add more commands than fit in the subscriber channel, without reading from slow
+	n := subscriberBufferSize * 4
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < n; i++ {
+			r.Add(MakeTestCommand([]string{}, string(rune('a'+i%26))+string(rune('a'+i/26))))
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Add blocked on a slow subscriber")
+	}
+
+	assert.Len(t, receiveEvents(t, fast, n), n)
+	assert.Len(t, receiveEvents(t, slow, n), n)
+}
+
+func TestSubscribeClosedOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r := NewRepository()
+	events := r.Subscribe(ctx)
+	cancel()
+
+	select {
+	case _, ok := <-events:
+		require.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed after cancel")
+	}
+
+	// publishing after the subscriber is gone must not panic
+	r.Add(MakeTestCommand([]string{}, "foo"))
+}
@@ -0,0 +1,196 @@
+package fs
+
+import (
+	claycmds "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Every directory of a repository can contain a _group.yaml file describing the command
+// group it forms, for example:
+//
+//	short: Database commands
+//	long: Commands to inspect and manage the databases.
+//	hidden: false
+//	order: 10
+//
+// The metadata is stored on the TrieNode of the group. Since trie nodes are created
+// and dropped along with the commands they contain, the repository keeps the metadata
+// of every group file and applies it to the trie after every change.
+
+// GroupMetadata describes a group of commands, such as the parent command created for
+// a directory of commands.
+type GroupMetadata struct {
+	Short  string `yaml:"short,omitempty"`
+	Long   string `yaml:"long,omitempty"`
+	Hidden bool   `yaml:"hidden,omitempty"`
+	// Order sorts groups that are next to each other, before sorting them by name.
+	// Groups without metadata come after the groups with metadata.
+	Order int `yaml:"order,omitempty"`
+
+	// Path is the path of the group in the repository.
+	Path []string `yaml:"-"`
+	// Source is the file the metadata was loaded from.
+	Source string `yaml:"-"`
+}
+
+// LoadGroupMetadata parses the content of a group file.
+func LoadGroupMetadata(content []byte) (*GroupMetadata, error) {
+	ret := &GroupMetadata{}
+	err := yaml.Unmarshal(content, ret)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// loadGroupFile loads the group file file and applies it to the trie.
+// It must be called with r.mu held.
+func (r *Repository) loadGroupFile(file string) ([]RepositoryEvent, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	// don't retry a broken file until it changes
+	r.hashes[file] = claycmds.HashContent(content)
+	group, err := LoadGroupMetadata(content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse group file %s", file)
+	}
+	group.Path = r.fileParents(file)
+	group.Source = file
+
+	if previous, ok := r.groups[file]; ok {
+		if node := r.Root.findNode(previous.Path, false); node != nil && node.Group == previous {
+			node.Group = nil
+		}
+	}
+	r.groups[file] = group
+	r.applyGroups()
+
+	return []RepositoryEvent{{Type: EventGroupUpdated, Source: file, Group: group.Path}}, nil
+}
+
+// removeGroupFiles removes the metadata of the group files at or below file.
+// It must be called with r.mu held.
+func (r *Repository) removeGroupFiles(file string) []RepositoryEvent {
+	events := []RepositoryEvent{}
+	for _, source := range r.groupFiles() {
+		if source != file && !strings.HasPrefix(source, file+string(filepath.Separator)) {
+			continue
+		}
+		group := r.groups[source]
+		delete(r.groups, source)
+		delete(r.hashes, source)
+		if node := r.Root.findNode(group.Path, false); node != nil && node.Group == group {
+			node.Group = nil
+		}
+		events = append(events, RepositoryEvent{Type: EventGroupUpdated, Source: source, Group: group.Path})
+	}
+	r.applyGroups()
+	return events
+}
+
+// loadGroupFiles (re)loads the group files of the repository directories whose content changed,
+// and removes the metadata of the group files that are gone.
+// It must be called with r.mu held.
+func (r *Repository) loadGroupFiles() []RepositoryEvent {
+	events := []RepositoryEvent{}
+	seen := map[string]bool{}
+	for _, dir := range r.Directories {
+		ignore := r.ignoreMatcher(dir)
+		err := fs.WalkDir(os.DirFS(dir), ".", func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if path != "." && (strings.HasPrefix(d.Name(), ".") || ignore.Match(path, d.IsDir())) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() || !claycmds.IsGroupFile(path) {
+				return nil
+			}
+			file := filepath.Join(dir, filepath.FromSlash(path))
+			seen[file] = true
+			content, err := os.ReadFile(file)
+			if err == nil {
+				if hash, ok := r.hashes[file]; ok && hash == claycmds.HashContent(content) {
+					return nil
+				}
+			}
+			events_, err := r.loadGroupFile(file)
+			if err != nil {
+				events = append(events, RepositoryEvent{Type: EventLoadError, Source: file, Error: err})
+				return nil
+			}
+			events = append(events, events_...)
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			events = append(events, RepositoryEvent{Type: EventLoadError, Source: dir, Error: err})
+		}
+	}
+
+	for _, file := range r.groupFiles() {
+		if !seen[file] {
+			events = append(events, r.removeGroupFiles(file)...)
+		}
+	}
+	return events
+}
+
+// applyGroups stores the metadata of every group on the trie node of the group, if it exists.
+// It must be called with r.mu held.
+func (r *Repository) applyGroups() {
+	for _, source := range r.groupFiles() {
+		group := r.groups[source]
+		if node := r.Root.findNode(group.Path, false); node != nil {
+			node.Group = group
+		}
+	}
+}
+
+func (r *Repository) groupFiles() []string {
+	ret := []string{}
+	for file := range r.groups {
+		ret = append(ret, file)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// GetGroup returns the metadata of the group at path, if the repository has a group file for it.
+func (r *Repository) GetGroup(path []string) (*GroupMetadata, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key := pathKey(path)
+	for _, source := range r.groupFiles() {
+		if pathKey(r.groups[source].Path) == key {
+			return r.groups[source], true
+		}
+	}
+	return nil, false
+}
+
+// Groups returns the metadata of all the groups of the repository, sorted by path.
+func (r *Repository) Groups() []*GroupMetadata {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ret := []*GroupMetadata{}
+	for _, source := range r.groupFiles() {
+		ret = append(ret, r.groups[source])
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return pathKey(ret[i].Path) < pathKey(ret[j].Path)
+	})
+	return ret
+}
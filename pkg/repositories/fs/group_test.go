@@ -0,0 +1,82 @@
+package fs
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+func TestGroupFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "ops/users.yaml", "name: users\n")
+	writeTestFile(t, dir, "ops/_group.yaml", "short: Operations\nlong: Operations commands\norder: 2\n")
+	writeTestFile(t, dir, "db/ls.yaml", "name: ls\n")
+	writeTestFile(t, dir, "db/_group.yaml", "short: Databases\nhidden: true\norder: 1\n")
+	writeTestFile(t, dir, "auth/login.yaml", "name: login\n")
+
+	r := newTestRepository(t, dir)
+	// group files are not commands
+	assert.Equal(t, []string{"auth login", "db ls", "ops users"}, getSortedFullNames(r))
+
+	group, ok := r.GetGroup([]string{"ops"})
+	require.True(t, ok)
+	assert.Equal(t, "Operations", group.Short)
+	assert.Equal(t, "Operations commands", group.Long)
+	assert.Equal(t, []string{"ops"}, group.Path)
+	assert.Equal(t, group, r.Root.Children["ops"].Group)
+
+	group, ok = r.GetGroup([]string{"db"})
+	require.True(t, ok)
+	assert.True(t, group.Hidden)
+	_, ok = r.GetGroup([]string{"auth"})
+	assert.False(t, ok)
+
+	// groups without metadata come last
+	assert.Equal(t, []string{"db", "ops", "auth"}, r.Root.SortedChildNames())
+	writeTestFile(t, dir, "auth/_group.yaml", "short: Authentication\norder: 0\n")
+	report, err := r.Reload()
+	require.NoError(t, err)
+	assert.False(t, report.HasChanges())
+	assert.Equal(t, []string{"auth", "db", "ops"}, r.Root.SortedChildNames())
+
+	// the metadata survives the group being emptied and filled again
+	r.Remove([]string{"ops"})
+	assert.Nil(t, r.Root.Children["ops"])
+	require.NoError(t, r.LoadFile(dir+"/ops/users.yaml"))
+	assert.Equal(t, "Operations", r.Root.Children["ops"].Group.Short)
+}
+
+func TestGroupFilesThroughWatcher(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "ops/users.yaml", "name: users\n")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := newTestRepository(t, dir)
+	events := r.Subscribe(ctx)
+
+	groupFile := writeTestFile(t, dir, "ops/_group.yaml", "short: Operations\n")
+	require.NoError(t, r.LoadFile(groupFile))
+	group, ok := r.GetGroup([]string{"ops"})
+	require.True(t, ok)
+	assert.Equal(t, "Operations", group.Short)
+
+	writeTestFile(t, dir, "ops/_group.yaml", "short: Ops\n")
+	require.NoError(t, r.LoadFile(groupFile))
+	assert.Equal(t, "Ops", r.Root.Children["ops"].Group.Short)
+
+	require.NoError(t, os.Remove(groupFile))
+	r.RemoveFile(groupFile)
+	_, ok = r.GetGroup([]string{"ops"})
+	assert.False(t, ok)
+	assert.Nil(t, r.Root.Children["ops"].Group)
+
+	received := receiveEvents(t, events, 3)
+	assert.Equal(t, []EventType{EventGroupUpdated, EventGroupUpdated, EventGroupUpdated}, getEventTypes(received))
+	assert.Equal(t, []string{"ops"}, received[0].Group)
+
+	writeTestFile(t, dir, "ops/_group.yaml", "short: [\n")
+	assert.Error(t, r.LoadFile(groupFile))
+}
@@ -1,18 +1,54 @@
 package fs
 
 import (
+	claycmds "github.com/go-go-golems/clay/pkg/cmds"
 	"github.com/go-go-golems/glazed/pkg/cmds"
 	"github.com/go-go-golems/glazed/pkg/cmds/alias"
 	"github.com/go-go-golems/glazed/pkg/cmds/loaders"
+	"github.com/rs/zerolog/log"
 	"os"
+	"path/filepath"
+	"time"
 )
 
+// LoadCommandsFromInputs loads the commands from a list of directories, individual files,
+// and .zip/.tar.gz command archives.
+//
+// Aliases are resolved against the commands of all the inputs. Aliases that can't be
+// resolved are returned as well, with their AliasedCommand set to nil.
+//
+// The files matched by the .clayignore file at the root of a directory or archive are skipped,
+// as are the files matching the exclude patterns, in gitignore syntax.
 func LoadCommandsFromInputs(
 	commandLoader loaders.CommandLoader,
 	inputs []string,
+	exclude ...string,
 ) ([]cmds.Command, error) {
+	repository, err := LoadRepositoryFromInputs(commandLoader, inputs, exclude...)
+	if err != nil {
+		return nil, err
+	}
+
+	commands := repository.CollectCommands([]string{}, true)
+	for _, alias_ := range repository.PendingAliases() {
+		commands = append(commands, alias_)
+	}
+
+	return commands, nil
+}
+
+// LoadRepositoryFromInputs loads the inputs like LoadCommandsFromInputs, and returns the
+// repository containing the commands, which records the provenance of every command,
+// see Repository.GetProvenance.
+func LoadRepositoryFromInputs(
+	commandLoader loaders.CommandLoader,
+	inputs []string,
+	exclude ...string,
+) (*Repository, error) {
 	files := []string{}
 	directories := []string{}
+	archives := []string{}
+	ignore := claycmds.NewIgnoreMatcher(exclude...)
 	for _, input := range inputs {
 		// check if is directory
 		s, err := os.Stat(input)
@@ -21,6 +57,10 @@ func LoadCommandsFromInputs(
 		}
 		if s.IsDir() {
 			directories = append(directories, input)
+		} else if claycmds.IsArchive(input) {
+			archives = append(archives, input)
+		} else if ignore.Match(input, false) {
+			log.Debug().Str("file", input).Msg("Skipping excluded file")
 		} else {
 			files = append(files, input)
 		}
@@ -29,6 +69,7 @@ func LoadCommandsFromInputs(
 	repository := NewRepository(
 		WithFSLoader(commandLoader),
 		WithDirectories(directories),
+		WithExclude(exclude...),
 	)
 
 	err := repository.LoadCommands()
@@ -36,20 +77,64 @@ func LoadCommandsFromInputs(
 		return nil, err
 	}
 
-	commands := repository.CollectCommands([]string{}, true)
 	for _, file := range files {
-		f, file_, err := loaders.FileNameToFsFilePath(file)
+		absFile, err := filepath.Abs(file)
+		if err != nil {
+			return nil, err
+		}
+		f, file_, err := loaders.FileNameToFsFilePath(absFile)
 		if err != nil {
 			return nil, err
 		}
 
-		cmds_, err := commandLoader.LoadCommands(f, file_, []cmds.CommandDescriptionOption{}, []alias.Option{})
+		cmds_, err := commandLoader.LoadCommands(f, file_,
+			[]cmds.CommandDescriptionOption{cmds.WithSource(absFile)},
+			[]alias.Option{alias.WithSource(absFile)},
+		)
 		if err != nil {
 			return nil, err
 		}
+		if content, err := os.ReadFile(absFile); err == nil {
+			repository.Provenances().Record(absFile, &claycmds.Provenance{
+				Kind:     claycmds.LocationInputFile,
+				Location: file,
+				Path:     absFile,
+				LoadedAt: time.Now(),
+				Hash:     claycmds.HashContent(content),
+			})
+		}
 
-		commands = append(commands, cmds_...)
+		repository.Add(cmds_...)
 	}
 
-	return commands, nil
+	for _, archive := range archives {
+		f, err := claycmds.OpenArchive(archive)
+		if err != nil {
+			return nil, err
+		}
+
+		cmds_, err := claycmds.LoadCommandsFromFSWithExclude(
+			f, ".", commandLoader,
+			[]cmds.CommandDescriptionOption{
+				cmds.WithPrependSource(archive + "/"),
+				cmds.WithStripParentsPrefix([]string{"."}),
+			},
+			[]alias.Option{
+				alias.WithPrependSource(archive + "/"),
+			},
+			exclude,
+		)
+		if err != nil {
+			return nil, err
+		}
+		absArchive, err := filepath.Abs(archive)
+		if err != nil {
+			absArchive = archive
+		}
+		repository.Provenances().RecordFS(f, cmds_, archive+"/", claycmds.LocationRepository, archive, absArchive)
+
+		repository.Add(cmds_...)
+	}
+
+	return repository, nil
 }
@@ -0,0 +1,52 @@
+package fs
+
+import (
+	claycmds "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/rs/zerolog/log"
+	"os"
+	"path/filepath"
+)
+
+// Files matching the .clayignore file at the root of a repository directory, or one of
+// the patterns passed to WithExclude, are never loaded: neither by LoadCommands, nor by
+// Reload or the watcher. Changing the .clayignore file reloads the repository, so that
+// the commands of newly ignored files are removed, and those of files that are no longer
+// ignored are loaded.
+
+// ignoreMatcher returns the matcher for the files of the repository directory dir.
+func (r *Repository) ignoreMatcher(dir string) *claycmds.IgnoreMatcher {
+	ignore, err := claycmds.LoadIgnoreMatcher(os.DirFS(dir), ".", r.exclude)
+	if err != nil {
+		log.Warn().Err(err).Str("directory", dir).Msg("Could not load ignore file")
+		return claycmds.NewIgnoreMatcher(r.exclude...)
+	}
+	return ignore
+}
+
+// isIgnored returns true if file is ignored by the repository directory it is in.
+func (r *Repository) isIgnored(file string) bool {
+	directory := r.fileDirectory(file)
+	if directory == "" {
+		return false
+	}
+	relPath, err := filepath.Rel(directory, file)
+	if err != nil {
+		return false
+	}
+	s, err := os.Stat(file)
+	return r.ignoreMatcher(directory).Match(relPath, err == nil && s.IsDir())
+}
+
+// isIgnoreFile returns true if file is the .clayignore file of one of the repository directories.
+func (r *Repository) isIgnoreFile(file string) bool {
+	if filepath.Base(file) != claycmds.IgnoreFileName {
+		return false
+	}
+	dir := filepath.Dir(file)
+	for _, directory := range r.Directories {
+		if directory == dir {
+			return true
+		}
+	}
+	return false
+}
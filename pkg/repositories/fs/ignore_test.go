@@ -0,0 +1,78 @@
+package fs
+
+import (
+	claycmds "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestIgnoredFilesAreNotLoaded(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, ".clayignore", "drafts/\n")
+	writeTestFile(t, dir, "ops/users.yaml", "name: users\n")
+	writeTestFile(t, dir, "ops/drafts/users.yaml", "name: draft\n")
+	writeTestFile(t, dir, "ops/fixtures/hosts.yaml", "name: hosts\n")
+
+	r := NewRepository(
+		WithFSLoader(claycmds.NewRawCommandLoader()),
+		WithDirectories([]string{dir}),
+		WithExclude("fixtures/"),
+	)
+	require.NoError(t, r.LoadCommands())
+	assert.Equal(t, []string{"ops users"}, getSortedFullNames(r))
+
+	// the watcher never loads ignored files
+	draft := writeTestFile(t, dir, "ops/drafts/new.yaml", "name: new\n")
+	require.NoError(t, r.LoadFile(draft))
+	fixture := writeTestFile(t, dir, "ops/fixtures/hosts.yaml", "name: hosts\nshort: changed\n")
+	require.NoError(t, r.LoadFile(fixture))
+	assert.Equal(t, []string{"ops users"}, getSortedFullNames(r))
+
+	report, err := r.Reload()
+	require.NoError(t, err)
+	assert.False(t, report.HasChanges())
+}
+
+func TestChangingIgnoreFileReloadsRepository(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "ops/users.yaml", "name: users\n")
+	writeTestFile(t, dir, "drafts/users.yaml", "name: users\n")
+
+	r := newTestRepository(t, dir)
+	assert.Equal(t, []string{"drafts users", "ops users"}, getSortedFullNames(r))
+
+	ignoreFile := writeTestFile(t, dir, ".clayignore", "drafts/\n")
+	require.NoError(t, r.LoadFile(ignoreFile))
+	assert.Equal(t, []string{"ops users"}, getSortedFullNames(r))
+
+	require.NoError(t, os.Remove(ignoreFile))
+	r.RemoveFile(ignoreFile)
+	assert.Equal(t, []string{"drafts users", "ops users"}, getSortedFullNames(r))
+}
+
+func TestLoadCommandsFromInputsSkipsIgnoredFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, ".clayignore", "*.draft.yaml\n")
+	writeTestFile(t, dir, "ops/users.yaml", "name: users\n")
+	writeTestFile(t, dir, "ops/users.draft.yaml", "name: draft\n")
+	writeTestFile(t, dir, "ops/hosts.yaml", "name: hosts\n")
+	file := writeTestFile(t, t.TempDir(), "fixtures/data.yaml", "name: data\n")
+
+	commands, err := LoadCommandsFromInputs(claycmds.NewRawCommandLoader(), []string{dir, file}, "hosts.yaml", "fixtures/")
+	require.NoError(t, err)
+	paths := []string{}
+	for _, c := range commands {
+		paths = append(paths, strings.Join(claycmds.CommandPath(c), " "))
+	}
+	sort.Strings(paths)
+	assert.Equal(t, []string{"ops users"}, paths)
+
+	commands, err = LoadCommandsFromInputs(claycmds.NewRawCommandLoader(), []string{filepath.Join(dir, "ops", "hosts.yaml")})
+	require.NoError(t, err)
+	assert.Len(t, commands, 1)
+}
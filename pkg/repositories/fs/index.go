@@ -0,0 +1,147 @@
+package fs
+
+import (
+	claycmds "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"path/filepath"
+	"strings"
+)
+
+// The repository keeps an index from source file to the commands and aliases it defined,
+// so that the watcher can remove exactly the commands of a deleted or renamed file,
+// and drop the commands a file no longer defines when it is rewritten.
+
+// pathKey returns the key of a command path in the index maps.
+func pathKey(path []string) string {
+	return strings.Join(path, " ")
+}
+
+// indexCommand records that command was loaded from its source file.
+// It must be called with r.mu held.
+func (r *Repository) indexCommand(command cmds.Command) {
+	source := claycmds.CommandSource(command)
+	if source == "" {
+		return
+	}
+	path := claycmds.CommandPath(command)
+	key := pathKey(path)
+
+	// the command replaced a command defined in another file
+	if previous, ok := r.sources[key]; ok {
+		r.unindexPath(previous, key)
+	}
+
+	r.sources[key] = source
+	r.files[source] = append(r.files[source], path)
+}
+
+// unindexCommand must be called with r.mu held.
+func (r *Repository) unindexCommand(command cmds.Command) {
+	key := pathKey(claycmds.CommandPath(command))
+	if source, ok := r.sources[key]; ok {
+		r.unindexPath(source, key)
+		delete(r.sources, key)
+	}
+}
+
+func (r *Repository) unindexPath(source string, key string) {
+	paths := r.files[source]
+	for i, path := range paths {
+		if pathKey(path) == key {
+			paths = append(paths[:i:i], paths[i+1:]...)
+			break
+		}
+	}
+	if len(paths) == 0 {
+		delete(r.files, source)
+	} else {
+		r.files[source] = paths
+	}
+}
+
+// removeCommand removes the single command at path and returns the resulting events.
+// It must be called with r.mu held.
+// The aliases of a removed command only go back to pending once refreshAliases is called.
+func (r *Repository) removeCommand(path []string) []RepositoryEvent {
+	if r.isAlias(path) {
+		pending, isPending := r.pending[pathKey(path)]
+		r.removeAliasDefinition(path)
+		if isPending {
+			r.unindexCommand(pending)
+			return []RepositoryEvent{}
+		}
+	}
+
+	command, ok := r.Root.RemoveCommand(path)
+	if !ok {
+		return []RepositoryEvent{}
+	}
+	r.unindexCommand(command)
+	return []RepositoryEvent{{Type: EventRemoved, Command: command}}
+}
+
+// replaceFile adds the commands loaded from file, and removes the commands that
+// file used to define but doesn't anymore.
+// It must be called with r.mu held.
+func (r *Repository) replaceFile(file string, commands []cmds.Command) []RepositoryEvent {
+	previousPaths := append([][]string{}, r.files[file]...)
+
+	events := r.add(commands...)
+
+	newKeys := map[string]bool{}
+	for _, command := range commands {
+		newKeys[pathKey(claycmds.CommandPath(command))] = true
+	}
+	for _, path := range previousPaths {
+		key := pathKey(path)
+		if newKeys[key] || r.sources[key] != file {
+			continue
+		}
+		events = append(events, r.removeCommand(path)...)
+	}
+
+	return append(events, r.refreshAliases()...)
+}
+
+// removeFile removes all the commands defined by file, or by files below it if it is a directory.
+// It must be called with r.mu held.
+func (r *Repository) removeFile(file string) []RepositoryEvent {
+	events := []RepositoryEvent{}
+
+	files := []string{}
+	for source := range r.files {
+		if source == file || strings.HasPrefix(source, file+string(filepath.Separator)) {
+			files = append(files, source)
+		}
+	}
+
+	for _, source := range files {
+		for _, path := range append([][]string{}, r.files[source]...) {
+			events = append(events, r.removeCommand(path)...)
+		}
+	}
+	for source := range r.hashes {
+		if source == file || strings.HasPrefix(source, file+string(filepath.Separator)) {
+			delete(r.hashes, source)
+		}
+	}
+	for _, source := range r.provenances.Sources() {
+		if source == file || strings.HasPrefix(source, file+string(filepath.Separator)) {
+			r.provenances.Remove(source)
+		}
+	}
+
+	return append(events, r.refreshAliases()...)
+}
+
+// GetFileCommands returns the paths of the commands and aliases defined by file.
+func (r *Repository) GetFileCommands(file string) [][]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ret := [][]string{}
+	for _, path := range r.files[file] {
+		ret = append(ret, append([]string{}, path...))
+	}
+	return ret
+}
@@ -0,0 +1,186 @@
+package fs
+
+import (
+	"fmt"
+	claycmds "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, dir string, name string, content string) string {
+	path := filepath.Join(dir, name)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	require.NoError(t, err)
+	err = os.WriteFile(path, []byte(content), 0644)
+	require.NoError(t, err)
+	return path
+}
+
+func getSortedFullNames(r *Repository) []string {
+	ret := []string{}
+	for _, c := range r.CollectCommands([]string{}, true) {
+		ret = append(ret, strings.Join(claycmds.CommandPath(c), " "))
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func newTestRepository(t *testing.T, dir string) *Repository {
+	r := NewRepository(
+		WithFSLoader(claycmds.NewRawCommandLoader()),
+		WithDirectories([]string{dir}),
+	)
+	err := r.LoadCommands()
+	require.NoError(t, err)
+	return r
+}
+
+func TestRemoveFileRemovesItsCommands(t *testing.T) {
+	dir := t.TempDir()
+	users := writeTestFile(t, dir, "ops/users.yaml", "name: users\nshort: List users\n")
+	writeTestFile(t, dir, "ops/hosts.yaml", "name: hosts\nshort: List hosts\n")
+	writeTestFile(t, dir, "ls.yaml", "name: ls\nshort: List\n")
+
+	r := newTestRepository(t, dir)
+	assert.Equal(t, []string{"ls", "ops hosts", "ops users"}, getSortedFullNames(r))
+	assert.Equal(t, [][]string{{"ops", "users"}}, r.GetFileCommands(users))
+
+	r.RemoveFile(users)
+	assert.Equal(t, []string{"ls", "ops hosts"}, getSortedFullNames(r))
+	assert.Empty(t, r.GetFileCommands(users))
+
+	// removing a directory removes everything below it
+	r.RemoveFile(filepath.Join(dir, "ops"))
+	assert.Equal(t, []string{"ls"}, getSortedFullNames(r))
+}
+
+func TestRemoveFileDropsItsProvenance(t *testing.T) {
+	dir := t.TempDir()
+	users := writeTestFile(t, dir, "ops/users.yaml", "name: users\nshort: List users\n")
+	writeTestFile(t, dir, "ls.yaml", "name: ls\nshort: List\n")
+
+	r := newTestRepository(t, dir)
+	command, ok := r.FindCommand([]string{"ops", "users"})
+	require.True(t, ok)
+	provenance, ok := r.GetProvenance(command)
+	require.True(t, ok)
+	assert.Equal(t, claycmds.LocationRepository, provenance.Kind)
+	assert.Equal(t, users, provenance.Path)
+
+	// reloading the file replaces its provenance
+	writeTestFile(t, dir, "ops/users.yaml", "name: users\nshort: All the users\n")
+	require.NoError(t, r.LoadFile(users))
+	command, _ = r.FindCommand([]string{"ops", "users"})
+	reloaded, ok := r.GetProvenance(command)
+	require.True(t, ok)
+	assert.NotEqual(t, provenance.Hash, reloaded.Hash)
+
+	r.RemoveFile(users)
+	_, ok = r.Provenances().GetSource(users)
+	assert.False(t, ok)
+	assert.Len(t, r.Provenances().Sources(), 1)
+}
+
+func TestLoadFileDropsCommandsNoLongerDefined(t *testing.T) {
+	dir := t.TempDir()
+	file := writeTestFile(t, dir, "ops/users.yaml", "name: users\nshort: List users\n")
+
+	r := newTestRepository(t, dir)
+	assert.Equal(t, []string{"ops users"}, getSortedFullNames(r))
+
+	writeTestFile(t, dir, "ops/users.yaml", "name: accounts\nshort: List accounts\n")
+	err := r.LoadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ops accounts"}, getSortedFullNames(r))
+	assert.Equal(t, [][]string{{"ops", "accounts"}}, r.GetFileCommands(file))
+}
+
+func TestRenameFile(t *testing.T) {
+	dir := t.TempDir()
+	oldFile := writeTestFile(t, dir, "ops/users.yaml", "name: users\nshort: List users\n")
+
+	r := newTestRepository(t, dir)
+
+	// a rename is reported by the watcher as a remove of the old path and a write of the new path
+	newFile := filepath.Join(dir, "admin", "users.yaml")
+	require.NoError(t, os.MkdirAll(filepath.Dir(newFile), 0755))
+	require.NoError(t, os.Rename(oldFile, newFile))
+	r.RemoveFile(oldFile)
+	require.NoError(t, r.LoadFile(newFile))
+
+	assert.Equal(t, []string{"admin users"}, getSortedFullNames(r))
+}
+
+func TestCommandReplacedByOtherFile(t *testing.T) {
+	dir := t.TempDir()
+	first := writeTestFile(t, dir, "ops/users.yaml", "name: users\nshort: List users\n")
+	second := writeTestFile(t, dir, "ops/users2.yaml", "name: users\nshort: List users again\n")
+
+	r := newTestRepository(t, dir)
+	require.NoError(t, r.LoadFile(second))
+	assert.Empty(t, r.GetFileCommands(first))
+
+	// removing the file that lost the command must not remove the command
+	r.RemoveFile(first)
+	c, ok := r.FindCommand([]string{"ops", "users"})
+	require.True(t, ok)
+	assert.Equal(t, "List users again", c.Description().Short)
+}
+
+func TestLoadCommandsWithWorkers(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 50; i++ {
+		writeTestFile(t, dir, fmt.Sprintf("ops/cmd%02d.yaml", i), fmt.Sprintf("name: cmd%02d\n", i))
+	}
+	writeTestFile(t, dir, "ops/cmd00/short.yaml", "name: short\naliasFor: cmd00\n")
+
+	sequential := newTestRepository(t, dir)
+	concurrent := NewRepository(
+		WithFSLoader(claycmds.NewRawCommandLoader()),
+		WithDirectories([]string{dir}),
+		WithWorkerCount(8),
+	)
+	require.NoError(t, concurrent.LoadCommands())
+
+	assert.Len(t, getSortedFullNames(concurrent), 51)
+	assert.Equal(t, getSortedFullNames(sequential), getSortedFullNames(concurrent))
+	assert.Equal(t, sequential.GetFileCommands(filepath.Join(dir, "ops/cmd00/short.yaml")),
+		concurrent.GetFileCommands(filepath.Join(dir, "ops/cmd00/short.yaml")))
+}
+
+func TestMountedDirectories(t *testing.T) {
+	ops := t.TempDir()
+	writeTestFile(t, ops, "users.yaml", "name: users\n")
+	writeTestFile(t, ops, "users/active.yaml", "name: active\naliasFor: users\n")
+	db := t.TempDir()
+	writeTestFile(t, db, "users.yaml", "name: users\n")
+	writeTestFile(t, db, "ls.yaml", "name: ls\n")
+
+	r := NewRepository(
+		WithFSLoader(claycmds.NewRawCommandLoader()),
+		WithDirectory(db),
+		WithMount([]string{"teams", "ops"}, ops),
+	)
+	require.NoError(t, r.LoadCommands())
+	assert.Equal(t, []string{
+		"ls",
+		"teams ops users",
+		"teams ops users active",
+		"users",
+	}, getSortedFullNames(r))
+
+	// files loaded by the watcher are mounted as well
+	hosts := writeTestFile(t, ops, "infra/hosts.yaml", "name: hosts\n")
+	require.NoError(t, r.LoadFile(hosts))
+	_, ok := r.FindCommand([]string{"teams", "ops", "infra", "hosts"})
+	assert.True(t, ok)
+
+	r.RemoveFile(hosts)
+	_, ok = r.FindCommand([]string{"teams", "ops", "infra", "hosts"})
+	assert.False(t, ok)
+}
@@ -0,0 +1,184 @@
+package fs
+
+import (
+	claycmds "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReloadError records a file that could not be loaded during Reload.
+type ReloadError struct {
+	File  string
+	Error error
+}
+
+// ReloadReport lists the changes applied by Reload.
+type ReloadReport struct {
+	Added   []cmds.Command
+	Updated []cmds.Command
+	Removed []cmds.Command
+	Errors  []ReloadError
+}
+
+// HasChanges returns true if the reload added, updated or removed any command.
+func (r *ReloadReport) HasChanges() bool {
+	return len(r.Added) > 0 || len(r.Updated) > 0 || len(r.Removed) > 0
+}
+
+// hashFiles records the content hash of all the files commands were loaded from,
+// so that the next Reload can skip the files that didn't change.
+// It must be called with r.mu held.
+func (r *Repository) hashFiles() {
+	for file := range r.files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			// not every source is a file on disk, for example embedded commands
+			continue
+		}
+		r.hashes[file] = claycmds.HashContent(content)
+	}
+}
+
+// listFiles returns all the files in the repository directories that the loader supports.
+func (r *Repository) listFiles() ([]string, error) {
+	ret := []string{}
+	for _, dir := range r.Directories {
+		dirFS := os.DirFS(dir)
+		ignore := r.ignoreMatcher(dir)
+		err := fs.WalkDir(dirFS, ".", func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if path != "." && (strings.HasPrefix(d.Name(), ".") || ignore.Match(path, d.IsDir())) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() || claycmds.IsGroupFile(path) || !r.fsLoader.IsFileSupported(dirFS, path) {
+				return nil
+			}
+			ret = append(ret, filepath.Join(dir, filepath.FromSlash(path)))
+			return nil
+		})
+		if err != nil {
+			if os.IsNotExist(err) {
+				log.Debug().Str("directory", dir).Msg("Repository directory does not exist")
+				continue
+			}
+			return nil, errors.Wrapf(err, "could not list files in %s", dir)
+		}
+	}
+	return ret, nil
+}
+
+// Reload rescans the repository directories and only reloads the files whose content changed,
+// including group files. The commands of files that are now ignored are removed.
+//
+// Commands from new or changed files are added or updated, commands from deleted files
+// are removed, as are commands that a changed file no longer defines. Only these changes
+// trigger callbacks and events, which makes Reload cheap to call on a long-running server,
+// for example when receiving a SIGHUP.
+func (r *Repository) Reload() (*ReloadReport, error) {
+	if r.fsLoader == nil {
+		return nil, errors.New("no command loader set")
+	}
+
+	files, err := r.listFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ReloadReport{}
+	events := []RepositoryEvent{}
+	seen := map[string]bool{}
+
+	for _, file := range files {
+		seen[file] = true
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			report.Errors = append(report.Errors, ReloadError{File: file, Error: err})
+			continue
+		}
+		hash := claycmds.HashContent(content)
+
+		r.mu.RLock()
+		previousHash, ok := r.hashes[file]
+		r.mu.RUnlock()
+		if ok && previousHash == hash {
+			continue
+		}
+
+		commands, err := r.loadFile(file)
+
+		r.mu.Lock()
+		// don't retry a broken file until it changes
+		r.hashes[file] = hash
+		if err == nil {
+			events = append(events, r.replaceFile(file, commands)...)
+			r.recordProvenance(file, hash)
+		}
+		r.mu.Unlock()
+
+		if err != nil {
+			report.Errors = append(report.Errors, ReloadError{File: file, Error: err})
+			events = append(events, RepositoryEvent{Type: EventLoadError, Source: file, Error: err})
+		}
+	}
+
+	// remove the commands of files that are gone
+	r.mu.Lock()
+	for file := range r.hashes {
+		// the hashes of group files are handled by loadGroupFiles
+		if !seen[file] && !claycmds.IsGroupFile(file) {
+			delete(r.hashes, file)
+		}
+	}
+	for file := range r.files {
+		if !seen[file] && r.isInDirectories(file) {
+			events = append(events, r.removeFile(file)...)
+		}
+	}
+	groupEvents := r.loadGroupFiles()
+	r.mu.Unlock()
+
+	for _, event := range groupEvents {
+		if event.Type == EventLoadError {
+			report.Errors = append(report.Errors, ReloadError{File: event.Source, Error: event.Error})
+		}
+	}
+	events = append(events, groupEvents...)
+
+	for _, event := range events {
+		switch event.Type {
+		case EventAdded:
+			report.Added = append(report.Added, event.Command)
+		case EventUpdated:
+			report.Updated = append(report.Updated, event.Command)
+		case EventRemoved:
+			report.Removed = append(report.Removed, event.Command)
+		case EventAliasResolved, EventAliasPending, EventLoadError, EventGroupUpdated:
+		}
+	}
+
+	r.notify(events)
+
+	return report, nil
+}
+
+// isInDirectories returns true if file is inside one of the repository directories.
+// Commands added from elsewhere (for example with WithCommands) are left alone by Reload.
+func (r *Repository) isInDirectories(file string) bool {
+	for _, dir := range r.Directories {
+		if strings.HasPrefix(file, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
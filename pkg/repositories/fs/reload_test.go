@@ -0,0 +1,101 @@
+package fs
+
+import (
+	"context"
+	claycmds "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func getCommandNames(commands []cmds.Command) []string {
+	ret := []string{}
+	for _, c := range commands {
+		ret = append(ret, strings.Join(claycmds.CommandPath(c), " "))
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func TestReloadWithoutChanges(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "ops/users.yaml", "name: users\nshort: List users\n")
+	writeTestFile(t, dir, "ls.yaml", "name: ls\nshort: List\n")
+
+	updates := 0
+	r := NewRepository(
+		WithFSLoader(claycmds.NewRawCommandLoader()),
+		WithDirectories([]string{dir}),
+		WithUpdateCallback(func(cmd cmds.Command) error {
+			updates++
+			return nil
+		}),
+	)
+	require.NoError(t, r.LoadCommands())
+	assert.Equal(t, 2, updates)
+
+	report, err := r.Reload()
+	require.NoError(t, err)
+	assert.False(t, report.HasChanges())
+	assert.Empty(t, report.Errors)
+	assert.Equal(t, 2, updates)
+}
+
+func TestReloadClassifiesChanges(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "ops/users.yaml", "name: users\nshort: List users\n")
+	hosts := writeTestFile(t, dir, "ops/hosts.yaml", "name: hosts\nshort: List hosts\n")
+	writeTestFile(t, dir, "ls.yaml", "name: ls\nshort: List\n")
+	writeTestFile(t, dir, "old.yaml", "name: old\nshort: Old\n")
+
+	r := newTestRepository(t, dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := r.Subscribe(ctx)
+
+	writeTestFile(t, dir, "ops/users.yaml", "name: users\nshort: List all users\n")
+	writeTestFile(t, dir, "ops/db.yaml", "name: db\nshort: Databases\n")
+	writeTestFile(t, dir, "old.yaml", "name: new\nshort: New\n")
+	require.NoError(t, os.Remove(hosts))
+
+	report, err := r.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"new", "ops db"}, getCommandNames(report.Added))
+	assert.Equal(t, []string{"ops users"}, getCommandNames(report.Updated))
+	assert.Equal(t, []string{"old", "ops hosts"}, getCommandNames(report.Removed))
+
+	assert.Equal(t, []string{"ls", "new", "ops db", "ops users"}, getSortedFullNames(r))
+	c, ok := r.FindCommand([]string{"ops", "users"})
+	require.True(t, ok)
+	assert.Equal(t, "List all users", c.Description().Short)
+
+	// only the changes are published
+	assert.Len(t, receiveEvents(t, events, 5), 5)
+	select {
+	case e := <-events:
+		t.Fatalf("unexpected event %v", e)
+	default:
+	}
+}
+
+func TestReloadReportsBrokenFilesOnce(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "ls.yaml", "name: ls\nshort: List\n")
+
+	r := newTestRepository(t, dir)
+
+	writeTestFile(t, dir, "broken.yaml", "name: [broken\n")
+	report, err := r.Reload()
+	require.NoError(t, err)
+	require.Len(t, report.Errors, 1)
+	assert.Contains(t, report.Errors[0].File, "broken.yaml")
+
+	report, err = r.Reload()
+	require.NoError(t, err)
+	assert.Empty(t, report.Errors)
+}
@@ -6,21 +6,34 @@ import (
 	"github.com/go-go-golems/glazed/pkg/cmds/alias"
 	"github.com/go-go-golems/glazed/pkg/cmds/loaders"
 	"github.com/go-go-golems/glazed/pkg/help"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
+	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 )
 
 // A repository is a collection of commands and aliases, that can optionally be reloaded
 // through a watcher (and for which you can register callbacks, for example to update a potential
 // cobra command or REST route).
+//
+// Multiple consumers can follow changes to the repository by calling Subscribe.
 
 type UpdateCallback func(cmd cmds.Command) error
 type RemoveCallback func(cmd cmds.Command) error
 
 type Repository struct {
 	// The root of the repository.
-	Root           *TrieNode
-	Directories    []string
+	//
+	// Root is guarded by mu, which is only taken by the repository methods.
+	// Use CollectCommands and FindCommand instead of accessing Root directly
+	// when the repository is being watched.
+	Root        *TrieNode
+	Directories []string
+	// mounts maps the directories mounted with WithMount to their prefix
+	mounts         map[string][]string
 	updateCallback UpdateCallback
 	removeCallback RemoveCallback
 
@@ -28,6 +41,33 @@ type Repository struct {
 	fsLoader loaders.CommandLoader
 	// these options are passed to the loader to create new descriptions
 	cmdOptions []cmds.CommandDescriptionOption
+	// workerCount is the number of files parsed concurrently by LoadCommands
+	workerCount int
+	// exclude are the gitignore patterns of the files that are never loaded, see ignore.go
+	exclude []string
+
+	mu sync.RWMutex
+
+	// files maps every source file to the paths of the commands it defines,
+	// sources maps the path of every command to its source file.
+	// See index.go.
+	files   map[string][][]string
+	sources map[string]string
+	// hashes stores the content hash of every loaded file, see Reload.
+	hashes map[string]string
+	// provenances stores the provenance of every loaded file, see GetProvenance.
+	provenances *claycmds.Provenances
+	// aliases stores every alias added to the repository, pending stores the aliases whose
+	// aliased command is not in the repository, and aliasErrors the reason why an alias
+	// can't be resolved, if any. See aliases.go.
+	aliases     map[string]*aliasDefinition
+	pending     map[string]*alias.CommandAlias
+	aliasErrors map[string]error
+	// groups maps group files to their metadata, see group.go.
+	groups map[string]*GroupMetadata
+
+	subscribersMu sync.Mutex
+	subscribers   map[*subscriber]struct{}
 }
 
 type RepositoryOption func(*Repository)
@@ -61,9 +101,44 @@ func WithCommandDescriptionOptions(cmdOptions []cmds.CommandDescriptionOption) R
 	}
 }
 
+// WithWorkerCount lets LoadCommands parse up to workerCount files concurrently.
+// The fs loader must be safe for concurrent use.
+func WithWorkerCount(workerCount int) RepositoryOption {
+	return func(r *Repository) {
+		r.workerCount = workerCount
+	}
+}
+
+// WithExclude skips the files matching the given gitignore patterns, relative to the root
+// of every repository directory, in addition to the .clayignore file of the directory.
+func WithExclude(patterns ...string) RepositoryOption {
+	return func(r *Repository) {
+		r.exclude = append(r.exclude, patterns...)
+	}
+}
+
+// WithMount adds directory to the repository, mounting its commands below prefix.
+// Files loaded by the watcher are mounted below prefix as well.
+func WithMount(prefix []string, directory string) RepositoryOption {
+	return func(r *Repository) {
+		absPath, err := filepath.Abs(directory)
+		if err != nil {
+			log.Warn().Err(err).Msgf("could not convert %s to absolute path", directory)
+			absPath = directory
+		}
+		r.Directories = append(r.Directories, absPath)
+		r.mounts[absPath] = append([]string{}, prefix...)
+	}
+}
+
 func WithDirectory(directory string) RepositoryOption {
 	return func(r *Repository) {
-		r.Directories = append(r.Directories, directory)
+		absPath, err := filepath.Abs(directory)
+		if err != nil {
+			log.Warn().Err(err).Msgf("could not convert %s to absolute path", directory)
+			absPath = directory
+		}
+		r.Directories = append(r.Directories, absPath)
 	}
 }
 
@@ -88,7 +163,17 @@ func WithCommands(commands ...cmds.Command) RepositoryOption {
 // NewRepository creates a new repository.
 func NewRepository(options ...RepositoryOption) *Repository {
 	ret := &Repository{
-		Root: NewTrieNode([]cmds.Command{}, []*alias.CommandAlias{}),
+		Root:        NewTrieNode([]cmds.Command{}, []*alias.CommandAlias{}),
+		mounts:      map[string][]string{},
+		files:       map[string][][]string{},
+		sources:     map[string]string{},
+		hashes:      map[string]string{},
+		provenances: claycmds.NewProvenances(),
+		aliases:     map[string]*aliasDefinition{},
+		pending:     map[string]*alias.CommandAlias{},
+		aliasErrors: map[string]error{},
+		groups:      map[string]*GroupMetadata{},
+		subscribers: map[*subscriber]struct{}{},
 	}
 	for _, opt := range options {
 		opt(ret)
@@ -106,76 +191,334 @@ func (r *Repository) LoadCommands() error {
 		// https://github.com/go-go-golems/glazed/issues/163
 		helpSystem := help.NewHelpSystem()
 		locations := claycmds.CommandLocations{
-			Repositories: r.Directories,
+			Repositories: []string{},
+			WorkerCount:  r.workerCount,
+			Exclude:      r.exclude,
+		}
+		for _, directory := range r.Directories {
+			if prefix, ok := r.mounts[directory]; ok {
+				locations.Mounts = append(locations.Mounts, claycmds.RepositoryMount{
+					Prefix:     prefix,
+					Repository: directory,
+				})
+				continue
+			}
+			locations.Repositories = append(locations.Repositories, directory)
 		}
 		commandLoader := claycmds.NewCommandLoader[cmds.Command](&locations)
 		commands, aliases, err := commandLoader.LoadCommands(r.fsLoader, helpSystem)
 		if err != nil {
+			r.publish(RepositoryEvent{
+				Type:   EventLoadError,
+				Source: strings.Join(r.Directories, ","),
+				Error:  err,
+			})
 			return err
 		}
-		r.Add(commands...)
+		// files that could not be parsed were skipped
+		for _, loadError := range commandLoader.LoadErrors() {
+			r.publish(RepositoryEvent{
+				Type:   EventLoadError,
+				Source: loadError.File,
+				Error:  loadError,
+			})
+		}
 		for _, alias_ := range aliases {
-			r.Add(alias_)
+			commands = append(commands, alias_)
 		}
+		r.Add(commands...)
+		r.provenances.Merge(commandLoader.Provenances())
+
+		r.mu.Lock()
+		r.hashFiles()
+		events := r.loadGroupFiles()
+		r.mu.Unlock()
+
+		r.notify(events)
 	}
 
 	return nil
 }
 
+// Add inserts the given commands and aliases into the repository.
+// Aliases are resolved against the commands and aliases present in the repository. Aliases whose
+// command is missing are kept pending until the command is added, see PendingAliases.
 func (r *Repository) Add(commands ...cmds.Command) {
-	aliases := []*alias.CommandAlias{}
+	r.mu.Lock()
+	events := r.add(commands...)
+	r.mu.Unlock()
+
+	r.notify(events)
+}
+
+// add inserts commands into the trie and returns the resulting events.
+// It must be called with r.mu held.
+func (r *Repository) add(commands ...cmds.Command) []RepositoryEvent {
+	events := []RepositoryEvent{}
 
 	for _, command := range commands {
-		_, isAlias := command.(*alias.CommandAlias)
-		if isAlias {
-			aliases = append(aliases, command.(*alias.CommandAlias))
+		if alias_, isAlias := command.(*alias.CommandAlias); isAlias {
+			r.addAliasDefinition(alias_)
 			continue
 		}
 
 		prefix := command.Description().Parents
+		events = append(events, r.insertEvent(prefix, command.Description().Name, command))
 		r.Root.InsertCommand(prefix, command)
-		if r.updateCallback != nil {
-			err := r.updateCallback(command)
-			if err != nil {
-				log.Warn().Err(err).Msg("error while updating command")
-			}
-		}
+		r.indexCommand(command)
 	}
 
-	for _, alias_ := range aliases {
-		prefix := alias_.Parents
-		aliasedCommand, ok := r.Root.FindCommand(prefix)
-		if !ok {
-			name := alias_.Name
-			log.Warn().Msgf("alias_ %s (prefix: %v) for %s not found", name, prefix, alias_.AliasFor)
-			continue
-		}
-		alias_.AliasedCommand = aliasedCommand
+	events = append(events, r.refreshAliases()...)
+	r.applyGroups()
+	return events
+}
 
-		r.Root.InsertCommand(prefix, alias_)
-		if r.updateCallback != nil {
-			err := r.updateCallback(alias_)
-			if err != nil {
-				log.Warn().Err(err).Msg("error while updating command")
-			}
-		}
+// insertEvent returns the event describing the insertion of command at prefix.
+func (r *Repository) insertEvent(prefix []string, name string, command cmds.Command) RepositoryEvent {
+	path := append(append([]string{}, prefix...), name)
+	if _, ok := r.Root.FindCommand(path); ok {
+		return RepositoryEvent{Type: EventUpdated, Command: command}
 	}
+	return RepositoryEvent{Type: EventAdded, Command: command}
 }
 
+// Remove removes the commands and aliases at the given prefixes, as well as everything below them.
 func (r *Repository) Remove(prefixes ...[]string) {
+	r.mu.Lock()
+	events := r.remove(prefixes...)
+	r.mu.Unlock()
+
+	r.notify(events)
+}
+
+// remove must be called with r.mu held.
+func (r *Repository) remove(prefixes ...[]string) []RepositoryEvent {
+	events := []RepositoryEvent{}
 	for _, prefix := range prefixes {
 		removedCommands := r.Root.Remove(prefix)
 		for _, command := range removedCommands {
+			if _, isAlias := command.(*alias.CommandAlias); isAlias {
+				r.removeAliasDefinition(claycmds.CommandPath(command))
+			}
+			r.unindexCommand(command)
+			events = append(events, RepositoryEvent{Type: EventRemoved, Command: command})
+		}
+		r.removePending(prefix)
+	}
+	events = append(events, r.refreshAliases()...)
+	r.applyGroups()
+	return events
+}
+
+// LoadFile (re)loads the commands defined in file, which has to be inside one of the
+// repository directories. Commands that the file used to define but doesn't anymore are removed.
+//
+// Ignored files are not loaded, and the commands they defined before being ignored are removed.
+// Loading the .clayignore file of a repository directory reloads the whole repository.
+func (r *Repository) LoadFile(file string) error {
+	if r.fsLoader == nil {
+		return errors.New("no command loader set")
+	}
+
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+
+	if r.isIgnoreFile(file) {
+		_, err = r.Reload()
+		return err
+	}
+	if r.isIgnored(file) {
+		log.Debug().Str("file", file).Msg("Skipping ignored file")
+		r.mu.Lock()
+		events := r.removeFile(file)
+		events = append(events, r.removeGroupFiles(file)...)
+		r.mu.Unlock()
+
+		r.notify(events)
+		return nil
+	}
+
+	if claycmds.IsGroupFile(file) {
+		r.mu.Lock()
+		events, err := r.loadGroupFile(file)
+		r.mu.Unlock()
+		if err != nil {
+			r.publish(RepositoryEvent{
+				Type:   EventLoadError,
+				Source: file,
+				Error:  err,
+			})
+			return err
+		}
+		r.notify(events)
+		return nil
+	}
+
+	commands, err := r.loadFile(file)
+	if err != nil {
+		r.publish(RepositoryEvent{
+			Type:   EventLoadError,
+			Source: file,
+			Error:  err,
+		})
+		return err
+	}
+
+	r.mu.Lock()
+	events := r.replaceFile(file, commands)
+	if content, err := os.ReadFile(file); err == nil {
+		r.hashes[file] = claycmds.HashContent(content)
+		r.recordProvenance(file, r.hashes[file])
+	}
+	r.mu.Unlock()
+
+	r.notify(events)
+	return nil
+}
+
+// fileParents returns the path of the group that file is in, computed from the location
+// of the file relative to the repository directories.
+func (r *Repository) fileParents(file string) []string {
+	// try to strip the innermost of r.Directories from path
+	// if it's not possible, then just use path
+	relPath := file
+	directory := r.fileDirectory(file)
+	if directory != "" {
+		relPath = strings.TrimPrefix(file, directory)
+	}
+	relPath = strings.TrimPrefix(relPath, string(filepath.Separator))
+
+	// get directory of file, below the prefix the directory is mounted at
+	parents := append([]string{}, r.mounts[directory]...)
+	if dir := filepath.Dir(relPath); dir != "." {
+		parents = append(parents, loaders.GetParentsFromDir(filepath.ToSlash(dir))...)
+	}
+	return parents
+}
+
+// recordProvenance records the provenance of the commands loaded from file.
+func (r *Repository) recordProvenance(file string, hash string) {
+	r.provenances.Record(file, &claycmds.Provenance{
+		Kind:     claycmds.LocationRepository,
+		Location: r.fileDirectory(file),
+		Path:     file,
+		LoadedAt: time.Now(),
+		Hash:     hash,
+	})
+}
+
+// Provenances returns the provenances of the commands of the repository. The provenances of the
+// files loaded by the repository are recorded automatically, commands added with Add can have
+// theirs recorded by the caller.
+func (r *Repository) Provenances() *claycmds.Provenances {
+	return r.provenances
+}
+
+// GetProvenance returns the provenance of a command or alias of the repository.
+func (r *Repository) GetProvenance(command cmds.Command) (*claycmds.Provenance, bool) {
+	return r.provenances.Get(command)
+}
+
+// fileDirectory returns the innermost repository directory containing file,
+// or an empty string if file is not inside any of them.
+func (r *Repository) fileDirectory(file string) string {
+	directory := ""
+	for _, dir := range r.Directories {
+		if strings.HasPrefix(file, dir+string(filepath.Separator)) && len(dir) > len(directory) {
+			directory = dir
+		}
+	}
+	return directory
+}
+
+// loadFile loads the commands in file, computing their parents from the
+// location of the file relative to the repository directories.
+func (r *Repository) loadFile(file string) ([]cmds.Command, error) {
+	parents := r.fileParents(file)
+	cmdOptions_ := append(append([]cmds.CommandDescriptionOption{}, r.cmdOptions...),
+		cmds.WithSource(file),
+		cmds.WithParents(parents...))
+	aliasOptions := []alias.Option{
+		alias.WithSource(file),
+		alias.WithParents(parents...),
+	}
+
+	fs_, filePath, err := loaders.FileNameToFsFilePath(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get fs and file path for %s", file)
+	}
+	if !r.fsLoader.IsFileSupported(fs_, filePath) {
+		log.Debug().Str("file", file).Msg("Skipping unsupported file")
+		return []cmds.Command{}, nil
+	}
+
+	return r.fsLoader.LoadCommands(fs_, filePath, cmdOptions_, aliasOptions)
+}
+
+// RemoveFile removes the commands defined in file, or in any file below it if it is a directory.
+// Removing the .clayignore file of a repository directory reloads the whole repository.
+func (r *Repository) RemoveFile(file string) {
+	file, err := filepath.Abs(file)
+	if err != nil {
+		log.Warn().Err(err).Msgf("could not convert %s to absolute path", file)
+	}
+
+	if r.isIgnoreFile(file) {
+		_, err = r.Reload()
+		if err != nil {
+			log.Warn().Err(err).Msgf("could not reload repository after removing %s", file)
+		}
+		return
+	}
+
+	r.mu.Lock()
+	events := r.removeFile(file)
+	events = append(events, r.removeGroupFiles(file)...)
+	r.mu.Unlock()
+
+	r.notify(events)
+}
+
+// notify runs the callbacks and publishes the events to the subscribers.
+// It is called after releasing r.mu, so that callbacks can query the repository.
+func (r *Repository) notify(events []RepositoryEvent) {
+	for _, event := range events {
+		switch event.Type {
+		case EventAdded, EventUpdated:
+			if r.updateCallback != nil {
+				err := r.updateCallback(event.Command)
+				if err != nil {
+					log.Warn().Err(err).Msg("error while updating command")
+				}
+			}
+		case EventRemoved:
 			if r.removeCallback != nil {
-				err := r.removeCallback(command)
+				err := r.removeCallback(event.Command)
 				if err != nil {
 					log.Warn().Err(err).Msg("error while removing command")
 				}
 			}
+		case EventAliasResolved, EventAliasPending, EventLoadError, EventGroupUpdated:
 		}
 	}
+
+	r.publish(events...)
 }
 
+// CollectCommands returns a snapshot of the commands and aliases under the given prefix.
 func (r *Repository) CollectCommands(prefix []string, recurse bool) []cmds.Command {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	return r.Root.CollectCommands(prefix, recurse)
 }
+
+// FindCommand returns the command or alias at the given path.
+func (r *Repository) FindCommand(path []string) (cmds.Command, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.Root.FindCommand(path)
+}
@@ -1,11 +1,13 @@
 package fs
 
 import (
+	"context"
 	"github.com/go-go-golems/glazed/pkg/cmds"
 	"github.com/go-go-golems/glazed/pkg/cmds/alias"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -208,3 +210,108 @@ func FuzzTrieNode(f *testing.F) {
 		}
 	})
 }
+
+// runConcurrently applies the inputs to the repository from multiple goroutines at once,
+// while readers are collecting commands. It is meant to be run with -race.
+func runConcurrently(t *testing.T, r *Repository, inputs []FuzzInput, writers int) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// a subscriber that is never read from must not block writers
+	_ = r.Subscribe(ctx)
+	events := r.Subscribe(ctx)
+	go func() {
+		for range events {
+		}
+	}()
+
+	wg := sync.WaitGroup{}
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i, input := range inputs {
+				if i%writers != w {
+					continue
+				}
+				switch input.verb {
+				case Add:
+					if input.name == "" {
+						continue
+					}
+					r.Add(MakeTestCommand(input.prefix, input.name))
+				case Remove:
+					if input.name == "" {
+						continue
+					}
+					r.Remove(append(append([]string{}, input.prefix...), input.name))
+				case RemovePrefix:
+					r.Remove(input.prefix)
+				case Collect:
+					_ = r.CollectCommands(input.prefix, true)
+				}
+			}
+		}(w)
+	}
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for round := 0; round < 10; round++ {
+				for _, input := range inputs {
+					commands := r.CollectCommands(input.prefix, true)
+					for _, c := range commands {
+						// touch the descriptions to catch races on the commands themselves
+						_ = getName(c)
+					}
+					_, _ = r.FindCommand(append(append([]string{}, input.prefix...), input.name))
+					_ = r.CollectCommands(input.prefix, false)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// the repository must still be consistent
+	for _, c := range r.CollectCommands([]string{}, true) {
+		found, ok := r.FindCommand(append(append([]string{}, c.Description().Parents...), c.Description().Name))
+		require.True(t, ok)
+		assert.Equal(t, getName(c), getName(found))
+	}
+}
+
+func TestRepositoryConcurrentAccess(t *testing.T) {
+	inputs := []FuzzInput{}
+	for i := 0; i < 200; i++ {
+		prefix := []string{"a", string(rune('a' + i%5))}
+		name := string(rune('a' + i%7))
+		inputs = append(inputs,
+			FuzzInput{prefix, name, Add},
+			FuzzInput{prefix[:1], name, Collect},
+		)
+		if i%3 == 0 {
+			inputs = append(inputs, FuzzInput{prefix, name, Remove})
+		}
+		if i%50 == 0 {
+			inputs = append(inputs, FuzzInput{prefix, "", RemovePrefix})
+		}
+	}
+
+	runConcurrently(t, NewRepository(), inputs, 8)
+}
+
+func FuzzRepositoryConcurrent(f *testing.F) {
+	inputs := []FuzzInput{
+		{[]string{"a", "b", "c"}, "test", Add},
+		{[]string{"a", "b"}, "test", Add},
+		{[]string{"a"}, "test", Collect},
+		{[]string{"a", "b", "c"}, "test", Remove},
+		{[]string{"a"}, "test", RemovePrefix},
+	}
+	f.Add(inputsToString(inputs))
+	f.Fuzz(func(t *testing.T, inputStr string) {
+		runConcurrently(t, NewRepository(), inputsFromString(inputStr), 4)
+	})
+}
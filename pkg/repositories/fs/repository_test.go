@@ -3,6 +3,7 @@ package fs
 import (
 	"github.com/go-go-golems/glazed/pkg/cmds"
 	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
@@ -24,6 +25,7 @@ func MakeTestCommand(parents []string, name string) cmds.Command {
 	description := &cmds.CommandDescription{
 		Name:    name,
 		Parents: parents,
+		Layers:  layers.NewParameterLayers(),
 	}
 	return &TestCommand{description}
 }
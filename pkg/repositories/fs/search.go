@@ -0,0 +1,264 @@
+package fs
+
+import (
+	claycmds "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SearchResult is a command matching a search query.
+type SearchResult struct {
+	Command cmds.Command
+	Path    []string
+	Score   int
+	// MatchedFields lists the fields that matched the query, for example "path" or "flags"
+	MatchedFields []string
+}
+
+type searchOptions struct {
+	parentPrefix    []string
+	sourceDirectory string
+	limit           int
+}
+
+type SearchOption func(*searchOptions)
+
+// WithParentPrefix only returns commands whose parents start with prefix.
+func WithParentPrefix(prefix ...string) SearchOption {
+	return func(o *searchOptions) {
+		o.parentPrefix = prefix
+	}
+}
+
+// WithSourceDirectory only returns commands loaded from files inside directory.
+func WithSourceDirectory(directory string) SearchOption {
+	return func(o *searchOptions) {
+		absPath, err := filepath.Abs(directory)
+		if err == nil {
+			directory = absPath
+		}
+		o.sourceDirectory = directory
+	}
+}
+
+// WithSearchLimit limits the number of results. 0 means no limit.
+func WithSearchLimit(limit int) SearchOption {
+	return func(o *searchOptions) {
+		o.limit = limit
+	}
+}
+
+// searchField is a piece of command metadata that can be matched, weighted by how
+// significant a match on it is.
+type searchField struct {
+	name   string
+	weight int
+	values []string
+}
+
+func getSearchFields(command cmds.Command) []searchField {
+	description := command.Description()
+	flags, arguments := getParameterNames(command)
+
+	return []searchField{
+		{name: "path", weight: 4, values: []string{strings.Join(claycmds.CommandPath(command), " ")}},
+		{name: "short", weight: 2, values: []string{description.Short}},
+		{name: "long", weight: 1, values: []string{description.Long}},
+		{name: "flags", weight: 2, values: flags},
+		{name: "arguments", weight: 2, values: arguments},
+	}
+}
+
+// getParameterNames returns the names of the flags and arguments of a command.
+// RawCommands don't parse their parameters into layers, so we read them from their YAML.
+func getParameterNames(command cmds.Command) ([]string, []string) {
+	flags := []string{}
+	arguments := []string{}
+
+	if rawCommand, ok := command.(*claycmds.RawCommand); ok {
+		for key, list := range map[string]*[]string{"flags": &flags, "arguments": &arguments} {
+			entries, _ := rawCommand.YAMLContent[key].([]interface{})
+			for _, entry := range entries {
+				m, _ := entry.(map[string]interface{})
+				if name, ok := m["name"].(string); ok {
+					*list = append(*list, name)
+				}
+			}
+		}
+		return flags, arguments
+	}
+
+	description := command.Description()
+	if description.Layers == nil {
+		return flags, arguments
+	}
+	description.Layers.ForEach(func(_ string, layer layers.ParameterLayer) {
+		layer.GetParameterDefinitions().ForEach(func(p *parameters.ParameterDefinition) {
+			if p.IsArgument {
+				arguments = append(arguments, p.Name)
+			} else {
+				flags = append(flags, p.Name)
+			}
+		})
+	})
+
+	return flags, arguments
+}
+
+// fuzzyScore matches pattern as a case-insensitive subsequence of text.
+// Consecutive characters, characters at the start of words and exact substrings score higher,
+// while characters skipped between two matches are penalized, so that a pattern scattered
+// across a long help text doesn't match.
+func fuzzyScore(pattern string, text string) (int, bool) {
+	pattern = strings.ToLower(pattern)
+	lowerText := strings.ToLower(text)
+	if pattern == "" {
+		return 0, false
+	}
+
+	patternRunes := []rune(pattern)
+	textRunes := []rune(lowerText)
+
+	// when the pattern is a substring, match it there rather than greedily from the start
+	start := 0
+	idx := strings.Index(lowerText, pattern)
+	if idx >= 0 {
+		start = len([]rune(lowerText[:idx]))
+	}
+
+	score := 0
+	p := 0
+	consecutive := 0
+	lastMatch := -1
+	for i := start; i < len(textRunes); i++ {
+		c := textRunes[i]
+		if p == len(patternRunes) {
+			break
+		}
+		if c != patternRunes[p] {
+			consecutive = 0
+			continue
+		}
+
+		score++
+		if lastMatch >= 0 {
+			score -= i - lastMatch - 1
+		}
+		if consecutive > 0 {
+			score += 2 * consecutive
+		}
+		if i == 0 || !unicode.IsLetter(textRunes[i-1]) && !unicode.IsDigit(textRunes[i-1]) {
+			score += 3
+		}
+		consecutive++
+		lastMatch = i
+		p++
+	}
+
+	if p < len(patternRunes) || score <= 0 {
+		return 0, false
+	}
+
+	if idx >= 0 {
+		score += 2 * len(patternRunes)
+		if idx == 0 {
+			score += 5
+		}
+	}
+
+	return score, true
+}
+
+// scoreCommand returns the score of command for the query terms.
+// Every term has to match at least one field.
+func scoreCommand(command cmds.Command, terms []string) (int, []string, bool) {
+	fields := getSearchFields(command)
+
+	total := 0
+	matchedFields := map[string]bool{}
+	for _, term := range terms {
+		best := 0
+		bestField := ""
+		for _, field := range fields {
+			for _, value := range field.values {
+				score, ok := fuzzyScore(term, value)
+				if ok && score*field.weight > best {
+					best = score * field.weight
+					bestField = field.name
+				}
+			}
+		}
+		if best == 0 {
+			return 0, nil, false
+		}
+		total += best
+		matchedFields[bestField] = true
+	}
+
+	ret := []string{}
+	for _, field := range fields {
+		if matchedFields[field.name] {
+			ret = append(ret, field.name)
+		}
+	}
+	return total, ret, true
+}
+
+// Search ranks the commands and aliases in the repository by fuzzy matching the query
+// against their full path, short and long help, flag names and argument names.
+//
+// The query is split on whitespace, and each term has to match at least one of these fields.
+// Results are sorted by decreasing score.
+func (r *Repository) Search(query string, options ...SearchOption) []SearchResult {
+	o := &searchOptions{}
+	for _, option := range options {
+		option(o)
+	}
+
+	terms := strings.Fields(query)
+	ret := []SearchResult{}
+	for _, command := range r.CollectCommands(o.parentPrefix, true) {
+		if command.Description() == nil {
+			continue
+		}
+		if o.sourceDirectory != "" &&
+			!strings.HasPrefix(claycmds.CommandSource(command), o.sourceDirectory+string(filepath.Separator)) {
+			continue
+		}
+
+		score := 0
+		matchedFields := []string{}
+		if len(terms) > 0 {
+			var ok bool
+			score, matchedFields, ok = scoreCommand(command, terms)
+			if !ok {
+				continue
+			}
+		}
+
+		ret = append(ret, SearchResult{
+			Command:       command,
+			Path:          claycmds.CommandPath(command),
+			Score:         score,
+			MatchedFields: matchedFields,
+		})
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].Score != ret[j].Score {
+			return ret[i].Score > ret[j].Score
+		}
+		return pathKey(ret[i].Path) < pathKey(ret[j].Path)
+	})
+
+	if o.limit > 0 && len(ret) > o.limit {
+		ret = ret[:o.limit]
+	}
+
+	return ret
+}
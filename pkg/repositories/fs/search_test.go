@@ -0,0 +1,96 @@
+package fs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func getResultPaths(results []SearchResult) []string {
+	ret := []string{}
+	for _, result := range results {
+		ret = append(ret, strings.Join(result.Path, " "))
+	}
+	return ret
+}
+
+func newSearchTestRepository(t *testing.T, dir string) *Repository {
+	writeTestFile(t, dir, "ops/users.yaml", `name: users
+short: List database users
+flags:
+  - name: host
+    type: string
+`)
+	writeTestFile(t, dir, "ops/hosts.yaml", `name: hosts
+short: List hosts
+arguments:
+  - name: pattern
+    type: string
+`)
+	writeTestFile(t, dir, "mysql/processlist.yaml", `name: processlist
+short: Show running queries
+long: Show the queries currently running on the server, like SHOW PROCESSLIST.
+`)
+	writeTestFile(t, dir, "ls.yaml", "name: ls\nshort: List files\n")
+	return newTestRepository(t, dir)
+}
+
+func TestFuzzyScore(t *testing.T) {
+	_, ok := fuzzyScore("usr", "users")
+	assert.True(t, ok)
+	_, ok = fuzzyScore("usx", "users")
+	assert.False(t, ok)
+
+	exact, _ := fuzzyScore("user", "ops users")
+	fuzzy, _ := fuzzyScore("user", "ops u s e r")
+	assert.Greater(t, exact, fuzzy)
+}
+
+func TestSearchRanksPathMatchesFirst(t *testing.T) {
+	r := newSearchTestRepository(t, t.TempDir())
+
+	results := r.Search("users")
+	require.NotEmpty(t, results)
+	assert.Equal(t, []string{"ops", "users"}, results[0].Path)
+	assert.Equal(t, []string{"path"}, results[0].MatchedFields)
+
+	results = r.Search("proc")
+	require.NotEmpty(t, results)
+	assert.Equal(t, "mysql processlist", getResultPaths(results)[0])
+}
+
+func TestSearchHelpAndParameters(t *testing.T) {
+	r := newSearchTestRepository(t, t.TempDir())
+
+	results := r.Search("running")
+	assert.Equal(t, []string{"mysql processlist"}, getResultPaths(results))
+
+	results = r.Search("host")
+	require.Len(t, results, 2)
+	// the path match ranks higher than the flag match
+	assert.Equal(t, []string{"ops hosts", "ops users"}, getResultPaths(results))
+	assert.Equal(t, []string{"flags"}, results[1].MatchedFields)
+
+	results = r.Search("pattern")
+	assert.Equal(t, []string{"ops hosts"}, getResultPaths(results))
+	assert.Equal(t, []string{"arguments"}, results[0].MatchedFields)
+
+	// every term has to match
+	assert.Empty(t, r.Search("users running"))
+}
+
+func TestSearchFilters(t *testing.T) {
+	dir := t.TempDir()
+	r := newSearchTestRepository(t, dir)
+
+	results := r.Search("list", WithParentPrefix("ops"))
+	assert.Equal(t, []string{"ops hosts", "ops users"}, getResultPaths(results))
+
+	results = r.Search("", WithSourceDirectory(filepath.Join(dir, "mysql")))
+	assert.Equal(t, []string{"mysql processlist"}, getResultPaths(results))
+
+	results = r.Search("list", WithSearchLimit(1))
+	assert.Len(t, results, 1)
+}
@@ -4,11 +4,14 @@ import (
 	"github.com/go-go-golems/glazed/pkg/cmds"
 	"github.com/go-go-golems/glazed/pkg/cmds/alias"
 	"github.com/rs/zerolog/log"
+	"sort"
 )
 
 type TrieNode struct {
 	Children map[string]*TrieNode
 	Commands []cmds.Command
+	// Group is the metadata of the group of commands formed by the node, if it has a group file.
+	Group *GroupMetadata
 }
 
 // NewTrieNode creates a new trie node.
@@ -60,6 +63,28 @@ func (t *TrieNode) Remove(prefix []string) []cmds.Command {
 	return removedCommands
 }
 
+// RemoveCommand removes the single command or alias at path,
+// leaving the commands nested below it in place.
+func (t *TrieNode) RemoveCommand(path []string) (cmds.Command, bool) {
+	if len(path) == 0 {
+		return nil, false
+	}
+	node := t.findNode(path[:len(path)-1], false)
+	if node == nil {
+		return nil, false
+	}
+
+	name := path[len(path)-1]
+	for i, c := range node.Commands {
+		if c.Description().Name == name {
+			node.Commands = append(node.Commands[:i:i], node.Commands[i+1:]...)
+			return c, true
+		}
+	}
+
+	return nil, false
+}
+
 // InsertCommand inserts a command in the trie, replacing it if it already exists.
 func (t *TrieNode) InsertCommand(prefix []string, command cmds.Command) {
 	node := t.findNode(prefix, true)
@@ -75,6 +100,35 @@ func (t *TrieNode) InsertCommand(prefix []string, command cmds.Command) {
 	node.Commands = append(node.Commands, command)
 }
 
+// SortedChildNames returns the names of the children of the node, sorted by
+// the order of their group metadata, then by name. Children without group metadata
+// come after the ones with metadata.
+func (t *TrieNode) SortedChildNames() []string {
+	ret := []string{}
+	for name := range t.Children {
+		ret = append(ret, name)
+	}
+	hasGroup := func(name string) bool {
+		return t.Children[name].Group != nil
+	}
+	order := func(name string) int {
+		if group := t.Children[name].Group; group != nil {
+			return group.Order
+		}
+		return 0
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if hasGroup(ret[i]) != hasGroup(ret[j]) {
+			return hasGroup(ret[i])
+		}
+		if order(ret[i]) != order(ret[j]) {
+			return order(ret[i]) < order(ret[j])
+		}
+		return ret[i] < ret[j]
+	})
+	return ret
+}
+
 // findNode finds the node corresponding to the given prefix, creating it if it doesn't exist.
 func (t *TrieNode) findNode(prefix []string, createNewNodes bool) *TrieNode {
 	node := t
@@ -141,7 +195,8 @@ func (t *TrieNode) CollectCommands(prefix []string, recurse bool) []cmds.Command
 	}
 
 	if !recurse {
-		return node.Commands
+		// return a copy, so that callers don't share the slice with the trie
+		return append(ret, node.Commands...)
 	}
 
 	// recurse into node to collect all commands and aliases
@@ -0,0 +1,132 @@
+package git
+
+import (
+	"context"
+	claycmds "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/go-go-golems/clay/pkg/repositories"
+	"github.com/go-go-golems/clay/pkg/repositories/fs"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/go-go-golems/glazed/pkg/cmds/loaders"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"strings"
+)
+
+// A Repository loads commands from a local git repository at a given branch, tag or commit.
+//
+// Files are read straight out of the git object database, so the working tree can be
+// edited (or not exist at all, for bare repositories) without affecting the loaded commands.
+// This allows pinning a server to a tagged version of a query repository.
+//
+// The Source of each command is "<commit>:<path>", which is also the syntax
+// git uses to refer to a file at a given commit (e.g. in git show).
+type Repository struct {
+	path string
+	ref  string
+	// directory is the directory inside the git tree the commands are loaded from
+	directory string
+	loader    loaders.CommandLoader
+	// these options are passed to the loader to create new descriptions
+	cmdOptions []cmds.CommandDescriptionOption
+
+	commit string
+}
+
+var _ repositories.Repository = (*Repository)(nil)
+
+type RepositoryOption func(*Repository)
+
+// WithLoader sets the command loader used to load the files in the git tree.
+func WithLoader(loader loaders.CommandLoader) RepositoryOption {
+	return func(r *Repository) {
+		r.loader = loader
+	}
+}
+
+// WithDirectory only loads commands from a directory inside the git tree.
+// The directory is not part of the parents of the loaded commands.
+func WithDirectory(directory string) RepositoryOption {
+	return func(r *Repository) {
+		r.directory = strings.Trim(directory, "/")
+	}
+}
+
+func WithCommandDescriptionOptions(cmdOptions []cmds.CommandDescriptionOption) RepositoryOption {
+	return func(r *Repository) {
+		r.cmdOptions = cmdOptions
+	}
+}
+
+// NewRepository creates a repository loading commands from the git repository at path,
+// at the given ref (branch, tag, commit hash or any revision git rev-parse understands).
+func NewRepository(path string, ref string, options ...RepositoryOption) *Repository {
+	ret := &Repository{
+		path:      path,
+		ref:       ref,
+		directory: ".",
+		loader:    claycmds.NewRawCommandLoader(),
+	}
+	for _, opt := range options {
+		opt(ret)
+	}
+	if ret.directory == "" {
+		ret.directory = "."
+	}
+	return ret
+}
+
+// Commit returns the hash of the commit the commands were last loaded from.
+func (r *Repository) Commit() string {
+	return r.commit
+}
+
+// LoadCommands resolves the ref and loads all the commands and aliases in its tree.
+func (r *Repository) LoadCommands(ctx context.Context) ([]cmds.Command, error) {
+	f, commit, err := NewTreeFS(ctx, r.path, r.ref, r.directory)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	prefix := commit + ":"
+	if r.directory != "." {
+		prefix += r.directory + "/"
+	}
+
+	options := append([]cmds.CommandDescriptionOption{}, r.cmdOptions...)
+	options = append(options,
+		cmds.WithPrependSource(prefix),
+		cmds.WithStripParentsPrefix([]string{"."}),
+	)
+	aliasOptions := []alias.Option{
+		alias.WithPrependSource(prefix),
+		alias.WithStripParentsPrefix([]string{"."}),
+	}
+
+	commands, err := claycmds.LoadCommandsFromFS(f, ".", r.loader, options, aliasOptions)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not load commands from %s at %s", r.path, r.ref)
+	}
+
+	r.commit = commit
+	return commands, nil
+}
+
+// CollectCommands loads all commands at the ref into an in-memory
+// repository and returns the commands under the given prefix.
+//
+// Errors are logged, because the repositories.Repository interface doesn't
+// allow returning them.
+func (r *Repository) CollectCommands(prefix []string, recurse bool) []cmds.Command {
+	commands, err := r.LoadCommands(context.Background())
+	if err != nil {
+		log.Warn().Err(err).Str("path", r.path).Str("ref", r.ref).Msg("could not load commands from git")
+		return []cmds.Command{}
+	}
+
+	repository := fs.NewRepository(fs.WithCommands(commands...))
+	return repository.CollectCommands(prefix, recurse)
+}
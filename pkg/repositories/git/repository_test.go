@@ -0,0 +1,151 @@
+package git
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func git(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1",
+	)
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	return strings.TrimSpace(string(out))
+}
+
+func writeFile(t *testing.T, dir string, name string, content string) {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func newTestGitRepository(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	git(t, dir, "init", "-q")
+	return dir
+}
+
+func getShorts(r *Repository) map[string]string {
+	ret := map[string]string{}
+	for _, c := range r.CollectCommands([]string{}, true) {
+		d := c.Description()
+		ret[strings.Join(append(append([]string{}, d.Parents...), d.Name), " ")] = d.Short
+	}
+	return ret
+}
+
+func TestLoadCommandsAtTag(t *testing.T) {
+	dir := newTestGitRepository(t)
+	writeFile(t, dir, "ops/users.yaml", "name: users\nshort: List users\n")
+	writeFile(t, dir, "ls.yaml", "name: ls\nshort: List\n")
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "-q", "-m", "v1")
+	git(t, dir, "tag", "v1.0.0")
+	v1 := git(t, dir, "rev-parse", "HEAD")
+
+	// changes committed after the tag, and uncommitted changes in the working tree
+	writeFile(t, dir, "ops/users.yaml", "name: users\nshort: List all users\n")
+	writeFile(t, dir, "ops/hosts.yaml", "name: hosts\nshort: List hosts\n")
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "-q", "-m", "v2")
+	writeFile(t, dir, "ls.yaml", "name: ls\nshort: List everything\n")
+
+	r := NewRepository(dir, "v1.0.0")
+	commands, err := r.LoadCommands(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, v1, r.Commit())
+
+	sources := []string{}
+	for _, c := range commands {
+		sources = append(sources, c.Description().Source)
+	}
+	sort.Strings(sources)
+	assert.Equal(t, []string{
+		v1 + ":ls.yaml",
+		v1 + ":ops/users.yaml",
+	}, sources)
+
+	assert.Equal(t, map[string]string{
+		"ls":        "List",
+		"ops users": "List users",
+	}, getShorts(r))
+
+	head := NewRepository(dir, "HEAD")
+	assert.Equal(t, map[string]string{
+		"ls":        "List",
+		"ops hosts": "List hosts",
+		"ops users": "List all users",
+	}, getShorts(head))
+}
+
+func TestLoadCommandsFromDirectory(t *testing.T) {
+	dir := newTestGitRepository(t)
+	writeFile(t, dir, "queries/ops/users.yaml", "name: users\nshort: List users\n")
+	writeFile(t, dir, "README.yaml", "name: readme\n")
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "-q", "-m", "init")
+
+	r := NewRepository(dir, "HEAD", WithDirectory("queries"))
+	commands, err := r.LoadCommands(context.Background())
+	require.NoError(t, err)
+	require.Len(t, commands, 1)
+	assert.Equal(t, []string{"ops"}, commands[0].Description().Parents)
+	assert.Equal(t, r.Commit()+":queries/ops/users.yaml", commands[0].Description().Source)
+}
+
+func TestUnknownRef(t *testing.T) {
+	dir := newTestGitRepository(t)
+	writeFile(t, dir, "ls.yaml", "name: ls\n")
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "-q", "-m", "init")
+
+	_, err := NewRepository(dir, "does-not-exist").LoadCommands(context.Background())
+	assert.Error(t, err)
+}
+
+func TestTreeFSOnlyListsDirectory(t *testing.T) {
+	dir := newTestGitRepository(t)
+	writeFile(t, dir, "queries/ops/users.yaml", "name: users\n")
+	writeFile(t, dir, "queries2/hosts.yaml", "name: hosts\n")
+	writeFile(t, dir, "data/large.csv", strings.Repeat("a,b\n", 1000))
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "-q", "-m", "init")
+
+	tree, _, err := NewTreeFS(context.Background(), dir, "HEAD", "queries")
+	require.NoError(t, err)
+	defer func() {
+		_ = tree.Close()
+	}()
+
+	files := []string{}
+	err = fs.WalkDir(tree, ".", func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, path)
+		}
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ops/users.yaml"}, files)
+
+	content, err := fs.ReadFile(tree, "ops/users.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "name: users\n", string(content))
+
+	_, _, err = NewTreeFS(context.Background(), dir, "HEAD", "missing")
+	assert.Error(t, err)
+}
@@ -0,0 +1,213 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	claycmds "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/pkg/errors"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// runGit runs git in the repository at path and returns its standard output.
+func runGit(ctx context.Context, path string, stdin io.Reader, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", path}, args...)...)
+	cmd.Stdin = stdin
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return nil, errors.Wrapf(err, "git %s: %s", strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// ResolveCommit returns the hash of the commit ref points to. ref can be
+// a branch, a tag, a commit hash or any other git revision.
+func ResolveCommit(ctx context.Context, path string, ref string) (string, error) {
+	out, err := runGit(ctx, path, nil, "rev-parse", "--verify", "--end-of-options", ref+"^{commit}")
+	if err != nil {
+		return "", errors.Wrapf(err, "could not resolve %s", ref)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+type treeEntry struct {
+	hash string
+	path string
+	size int64
+}
+
+// listTree returns the regular files in the tree of commit below directory, with their path
+// relative to directory. Symlinks and submodules are skipped.
+func listTree(ctx context.Context, path string, commit string, directory string) ([]treeEntry, error) {
+	args := []string{"ls-tree", "-r", "-z", "-l", "--full-tree", commit}
+	prefix := ""
+	if directory != "." {
+		prefix = directory + "/"
+		args = append(args, "--", prefix)
+	}
+	out, err := runGit(ctx, path, nil, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []treeEntry{}
+	for _, line := range strings.Split(string(out), "\x00") {
+		if line == "" {
+			continue
+		}
+		// <mode> SP <type> SP <object> SP+ <size> TAB <file>
+		meta, file, ok := strings.Cut(line, "\t")
+		if !ok {
+			return nil, errors.Errorf("could not parse ls-tree output %q", line)
+		}
+		fields := strings.Fields(meta)
+		if len(fields) != 4 {
+			return nil, errors.Errorf("could not parse ls-tree output %q", line)
+		}
+		mode, type_, hash := fields[0], fields[1], fields[2]
+		if type_ != "blob" || mode == "120000" {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not parse size of %s", file)
+		}
+		ret = append(ret, treeEntry{hash: hash, path: strings.TrimPrefix(file, prefix), size: size})
+	}
+	return ret, nil
+}
+
+// blobReader reads blobs with a single git cat-file process, started on the first read.
+type blobReader struct {
+	ctx  context.Context
+	path string
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+func (b *blobReader) start() error {
+	cmd := exec.CommandContext(b.ctx, "git", "-C", b.path, "cat-file", "--batch")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return errors.Wrap(err, "could not start git cat-file")
+	}
+	b.cmd = cmd
+	b.stdin = stdin
+	b.stdout = bufio.NewReader(stdout)
+	return nil
+}
+
+func (b *blobReader) read(hash string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.cmd == nil {
+		err := b.start()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err := io.WriteString(b.stdin, hash+"\n")
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read blob %s", hash)
+	}
+	// <object> SP <type> SP <size> LF <contents> LF
+	header, err := b.stdout.ReadString('\n')
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read blob %s", hash)
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return nil, errors.Errorf("unexpected cat-file output %q for %s", header, hash)
+	}
+	size, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse size of %s", hash)
+	}
+	content := make([]byte, size+1)
+	_, err = io.ReadFull(b.stdout, content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read blob %s", hash)
+	}
+	return content[:size], nil
+}
+
+func (b *blobReader) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.cmd == nil {
+		return nil
+	}
+	_ = b.stdin.Close()
+	err := b.cmd.Wait()
+	b.cmd = nil
+	return err
+}
+
+// TreeFS is a read-only fs.FS with the files of a git tree. The content of the files is
+// read from the git object database when they are opened, so TreeFS has to be closed.
+type TreeFS struct {
+	*claycmds.MemoryFS
+	blobs *blobReader
+}
+
+// Close stops the git process reading the files.
+func (t *TreeFS) Close() error {
+	return t.blobs.Close()
+}
+
+// NewTreeFS returns an fs.FS with the files of directory in the tree at ref, read straight
+// from the git object database, without needing a checkout. Only the files that are opened
+// are read. It also returns the hash of the commit ref resolved to.
+func NewTreeFS(ctx context.Context, path string, ref string, directory string) (*TreeFS, string, error) {
+	commit, err := ResolveCommit(ctx, path, ref)
+	if err != nil {
+		return nil, "", err
+	}
+
+	directory = strings.Trim(directory, "/")
+	if directory == "" {
+		directory = "."
+	}
+	entries, err := listTree(ctx, path, commit, directory)
+	if err != nil {
+		return nil, "", err
+	}
+	if directory != "." && len(entries) == 0 {
+		return nil, "", errors.Errorf("directory %s not found at %s", directory, ref)
+	}
+
+	ret := &TreeFS{
+		MemoryFS: claycmds.NewMemoryFS(),
+		blobs:    &blobReader{ctx: ctx, path: path},
+	}
+	for _, entry := range entries {
+		hash := entry.hash
+		ret.AddLazyFile(entry.path, entry.size, time.Time{}, func() ([]byte, error) {
+			return ret.blobs.read(hash)
+		})
+	}
+
+	return ret, commit, nil
+}
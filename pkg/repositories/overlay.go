@@ -0,0 +1,133 @@
+package repositories
+
+import (
+	claycmds "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"strings"
+)
+
+// OverlayLayer is a named repository that is part of an OverlayRepository.
+type OverlayLayer struct {
+	Name       string
+	Repository Repository
+}
+
+// OverlayRepository stacks multiple repositories on top of each other.
+//
+// Layers are given in increasing order of priority: when two layers provide a command
+// at the same path, the command from the later layer wins and the other one is shadowed.
+// This mirrors how commands loaded later replace earlier ones in a single fs.Repository,
+// for example a company-wide directory, followed by a team directory, followed by a
+// personal directory.
+type OverlayRepository struct {
+	Layers []OverlayLayer
+}
+
+var _ Repository = (*OverlayRepository)(nil)
+
+func NewOverlayRepository(layers ...OverlayLayer) *OverlayRepository {
+	return &OverlayRepository{
+		Layers: layers,
+	}
+}
+
+// OverlayEntry is a command as provided by a single layer.
+type OverlayEntry struct {
+	Layer string
+	// Repository is the repository of the layer
+	Repository Repository
+	Command    cmds.Command
+}
+
+// Conflict lists all the commands found at the same path.
+// Winner is the command returned by CollectCommands, Shadowed the ones it hides,
+// in decreasing order of priority.
+type Conflict struct {
+	Path     []string
+	Winner   OverlayEntry
+	Shadowed []OverlayEntry
+}
+
+// collectEntries returns all the commands found under prefix across all layers,
+// grouped by path, in the order they were first encountered.
+func (o *OverlayRepository) collectEntries(prefix []string, recurse bool) ([]string, map[string][]OverlayEntry) {
+	keys := []string{}
+	entries := map[string][]OverlayEntry{}
+
+	for _, layer := range o.Layers {
+		for _, command := range layer.Repository.CollectCommands(prefix, recurse) {
+			key := strings.Join(claycmds.CommandPath(command), " ")
+			if _, ok := entries[key]; !ok {
+				keys = append(keys, key)
+			}
+			entries[key] = append(entries[key], OverlayEntry{
+				Layer:      layer.Name,
+				Repository: layer.Repository,
+				Command:    command,
+			})
+		}
+	}
+
+	return keys, entries
+}
+
+// CollectCommands returns the merged view of all layers: for every path, only the
+// command of the layer with the highest priority is returned.
+func (o *OverlayRepository) CollectCommands(prefix []string, recurse bool) []cmds.Command {
+	keys, entries := o.collectEntries(prefix, recurse)
+
+	ret := make([]cmds.Command, 0, len(keys))
+	for _, key := range keys {
+		entries_ := entries[key]
+		ret = append(ret, entries_[len(entries_)-1].Command)
+	}
+	return ret
+}
+
+// Which returns the commands found at path across all layers, in decreasing order of priority:
+// the first entry is the one returned by CollectCommands, the others are shadowed by it.
+func (o *OverlayRepository) Which(path []string) []OverlayEntry {
+	key := strings.Join(path, " ")
+
+	ret := []OverlayEntry{}
+	for i := len(o.Layers) - 1; i >= 0; i-- {
+		layer := o.Layers[i]
+		for _, command := range layer.Repository.CollectCommands(path, false) {
+			if strings.Join(claycmds.CommandPath(command), " ") == key {
+				ret = append(ret, OverlayEntry{
+					Layer:      layer.Name,
+					Repository: layer.Repository,
+					Command:    command,
+				})
+			}
+		}
+	}
+	return ret
+}
+
+// Conflicts returns all the paths that are provided by more than one layer.
+func (o *OverlayRepository) Conflicts() []*Conflict {
+	keys, entries := o.collectEntries([]string{}, true)
+
+	ret := []*Conflict{}
+	for _, key := range keys {
+		entries_ := entries[key]
+		if len(entries_) < 2 {
+			continue
+		}
+
+		winner := entries_[len(entries_)-1]
+		shadowed := []OverlayEntry{}
+		for i := len(entries_) - 2; i >= 0; i-- {
+			shadowed = append(shadowed, entries_[i])
+		}
+
+		ret = append(ret, &Conflict{
+			Path:     claycmds.CommandPath(winner.Command),
+			Winner:   winner,
+			Shadowed: shadowed,
+		})
+	}
+
+	return ret
+}
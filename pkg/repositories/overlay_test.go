@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"github.com/go-go-golems/clay/pkg/repositories/fs"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func makeCommand(source string, parents []string, name string) cmds.Command {
+	return cmds.NewCommandDescription(name, cmds.WithParents(parents...), cmds.WithSource(source))
+}
+
+func makeOverlay() *OverlayRepository {
+	return NewOverlayRepository(
+		OverlayLayer{
+			Name: "company",
+			Repository: fs.NewRepository(fs.WithCommands(
+				makeCommand("company/ls.yaml", []string{}, "ls"),
+				makeCommand("company/ops/users.yaml", []string{"ops"}, "users"),
+				makeCommand("company/ops/db.yaml", []string{"ops"}, "db"),
+			)),
+		},
+		OverlayLayer{
+			Name: "team",
+			Repository: fs.NewRepository(fs.WithCommands(
+				makeCommand("team/ops/users.yaml", []string{"ops"}, "users"),
+			)),
+		},
+		OverlayLayer{
+			Name: "personal",
+			Repository: fs.NewRepository(fs.WithCommands(
+				makeCommand("personal/ops/users.yaml", []string{"ops"}, "users"),
+				makeCommand("personal/ops/mine.yaml", []string{"ops"}, "mine"),
+			)),
+		},
+	)
+}
+
+func getSources(commands []cmds.Command) []string {
+	ret := []string{}
+	for _, c := range commands {
+		ret = append(ret, c.Description().Source)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func TestOverlayCollectCommands(t *testing.T) {
+	o := makeOverlay()
+
+	assert.Equal(t, []string{
+		"company/ls.yaml",
+		"company/ops/db.yaml",
+		"personal/ops/mine.yaml",
+		"personal/ops/users.yaml",
+	}, getSources(o.CollectCommands([]string{}, true)))
+
+	assert.Equal(t, []string{
+		"personal/ops/users.yaml",
+	}, getSources(o.CollectCommands([]string{"ops", "users"}, false)))
+}
+
+func TestOverlayConflicts(t *testing.T) {
+	o := makeOverlay()
+
+	conflicts := o.Conflicts()
+	require.Len(t, conflicts, 1)
+
+	c := conflicts[0]
+	assert.Equal(t, "ops users", strings.Join(c.Path, " "))
+	assert.Equal(t, "personal", c.Winner.Layer)
+	require.Len(t, c.Shadowed, 2)
+	assert.Equal(t, "team", c.Shadowed[0].Layer)
+	assert.Equal(t, "team/ops/users.yaml", c.Shadowed[0].Command.Description().Source)
+	assert.Equal(t, "company", c.Shadowed[1].Layer)
+}
+
+func TestOverlayWhich(t *testing.T) {
+	o := makeOverlay()
+
+	entries := o.Which([]string{"ops", "users"})
+	require.Len(t, entries, 3)
+	assert.Equal(t, "personal", entries[0].Layer)
+	assert.Equal(t, "personal/ops/users.yaml", entries[0].Command.Description().Source)
+	assert.Same(t, o.Layers[2].Repository, entries[0].Repository)
+	assert.Equal(t, "team", entries[1].Layer)
+	assert.Equal(t, "company", entries[2].Layer)
+
+	entries = o.Which([]string{"ls"})
+	require.Len(t, entries, 1)
+	assert.Equal(t, "company", entries[0].Layer)
+
+	assert.Empty(t, o.Which([]string{"ops"}))
+}
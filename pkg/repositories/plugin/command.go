@@ -0,0 +1,147 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Description is the command description a plugin prints when called with --describe.
+//
+// It is printed as JSON, for example:
+//
+//	{
+//	  "name": "users",
+//	  "short": "List users",
+//	  "parents": ["ops"],
+//	  "flags": [{"name": "limit", "type": "int", "default": 10}],
+//	  "arguments": [{"name": "team", "type": "string", "required": true}]
+//	}
+//
+// The flags and arguments use the parameter definitions of glazed commands.
+// The description is parsed as YAML, a superset of JSON, so that the defaults keep
+// their types. Name defaults to the name of the plugin.
+type Description struct {
+	Name      string                            `yaml:"name"`
+	Short     string                            `yaml:"short"`
+	Long      string                            `yaml:"long,omitempty"`
+	Parents   []string                          `yaml:"parents,omitempty"`
+	Flags     []*parameters.ParameterDefinition `yaml:"flags,omitempty"`
+	Arguments []*parameters.ParameterDefinition `yaml:"arguments,omitempty"`
+}
+
+// ParseDescription parses the output of a plugin called with --describe.
+func ParseDescription(data []byte) (*Description, error) {
+	ret := &Description{}
+	err := yaml.Unmarshal(data, ret)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse plugin description")
+	}
+	return ret, nil
+}
+
+// Command runs a plugin executable. It sends the parsed parameters of the command to the plugin
+// as a JSON object on stdin, and reads the rows it outputs as JSON objects, one per line.
+// The stderr of the plugin is forwarded.
+type Command struct {
+	*cmds.CommandDescription
+	// Path is the path of the plugin executable
+	Path string
+}
+
+var _ cmds.GlazeCommand = (*Command)(nil)
+
+// NewCommand creates the command of the plugin at path from its description.
+func NewCommand(path string, description *Description, options ...cmds.CommandDescriptionOption) (*Command, error) {
+	glazedParameterLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, argument := range description.Arguments {
+		argument.IsArgument = true
+	}
+	options_ := append([]cmds.CommandDescriptionOption{
+		cmds.WithShort(description.Short),
+		cmds.WithLong(description.Long),
+		cmds.WithFlags(description.Flags...),
+		cmds.WithArguments(description.Arguments...),
+		cmds.WithLayersList(glazedParameterLayer),
+		cmds.WithParents(description.Parents...),
+		cmds.WithSource(path),
+	}, options...)
+
+	return &Command{
+		CommandDescription: cmds.NewCommandDescription(description.Name, options_...),
+		Path:               path,
+	}, nil
+}
+
+func (c *Command) RunIntoGlazeProcessor(
+	ctx context.Context,
+	parsedLayers *layers.ParsedLayers,
+	gp middlewares.Processor,
+) error {
+	values, err := parsedLayers.GetDefaultParameterLayer().Parameters.ToInterfaceMap()
+	if err != nil {
+		return err
+	}
+	input, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, c.Path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return errors.Wrapf(err, "could not start plugin %s", c.Path)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		// rows keep the order of the fields output by the plugin
+		row := types.NewRow()
+		err = json.Unmarshal([]byte(line), row)
+		if err == nil {
+			err = gp.AddRow(ctx, row)
+		} else {
+			err = errors.Wrapf(err, "plugin %s output an invalid row", c.Path)
+		}
+		if err != nil {
+			// stop the plugin, its remaining output is discarded
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+			return err
+		}
+	}
+	scanErr := scanner.Err()
+
+	err = cmd.Wait()
+	if err != nil {
+		return errors.Wrapf(err, "plugin %s failed", c.Path)
+	}
+	return scanErr
+}
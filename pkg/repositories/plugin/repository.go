@@ -0,0 +1,241 @@
+package plugin
+
+import (
+	"context"
+	claycmds "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/go-go-golems/clay/pkg/repositories"
+	"github.com/go-go-golems/clay/pkg/repositories/fs"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A Repository exposes plugin executables as commands, in the style of git subcommands.
+//
+// Plugins of an application are the executables named "<app>-<name>" in the plugin
+// directories or on PATH, for example clay-users for the app clay. When the same plugin
+// name is found several times, the first one wins, the plugin directories being searched
+// before PATH.
+//
+// Every plugin is called with --describe and prints its Description as JSON. Running the
+// command calls the plugin without arguments, see Command for the protocol. This allows
+// writing commands in any language, while still using the glazed output formatting.
+type Repository struct {
+	appName     string
+	directories []string
+	usePath     bool
+	// timeout is how long a plugin has to print its description
+	timeout time.Duration
+	// these options are passed to every loaded command
+	cmdOptions []cmds.CommandDescriptionOption
+
+	loadErrors  []*claycmds.LoadError
+	provenances *claycmds.Provenances
+}
+
+var _ repositories.Repository = (*Repository)(nil)
+
+type RepositoryOption func(*Repository)
+
+// WithDirectories searches plugins in the given directories, before PATH.
+func WithDirectories(directories ...string) RepositoryOption {
+	return func(r *Repository) {
+		r.directories = append(r.directories, directories...)
+	}
+}
+
+// WithPath sets whether plugins are searched on PATH, which they are by default.
+func WithPath(usePath bool) RepositoryOption {
+	return func(r *Repository) {
+		r.usePath = usePath
+	}
+}
+
+// WithDescribeTimeout sets how long a plugin has to print its description, 10 seconds by default.
+func WithDescribeTimeout(timeout time.Duration) RepositoryOption {
+	return func(r *Repository) {
+		r.timeout = timeout
+	}
+}
+
+func WithCommandDescriptionOptions(cmdOptions []cmds.CommandDescriptionOption) RepositoryOption {
+	return func(r *Repository) {
+		r.cmdOptions = cmdOptions
+	}
+}
+
+// NewRepository creates a repository of the plugins of the application appName.
+func NewRepository(appName string, options ...RepositoryOption) *Repository {
+	ret := &Repository{
+		appName:     appName,
+		usePath:     true,
+		timeout:     10 * time.Second,
+		provenances: claycmds.NewProvenances(),
+	}
+	for _, opt := range options {
+		opt(ret)
+	}
+	return ret
+}
+
+// LoadErrors returns the plugins that could not be described by the last call to LoadCommands.
+func (r *Repository) LoadErrors() []*claycmds.LoadError {
+	return r.loadErrors
+}
+
+// GetProvenance returns the provenance of a plugin command described by the repository.
+func (r *Repository) GetProvenance(command cmds.Command) (*claycmds.Provenance, bool) {
+	return r.provenances.Get(command)
+}
+
+// FindPlugins returns the paths of the plugin executables, sorted by plugin name.
+func (r *Repository) FindPlugins() []string {
+	directories := append([]string{}, r.directories...)
+	if r.usePath {
+		directories = append(directories, filepath.SplitList(os.Getenv("PATH"))...)
+	}
+
+	prefix := r.appName + "-"
+	plugins := map[string]string{}
+	for _, directory := range directories {
+		if directory == "" {
+			continue
+		}
+		entries, err := os.ReadDir(directory)
+		if err != nil {
+			log.Debug().Err(err).Str("directory", directory).Msg("Could not read plugin directory")
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if !strings.HasPrefix(name, prefix) || len(name) == len(prefix) {
+				continue
+			}
+			pluginName := pluginName(strings.TrimPrefix(name, prefix))
+			if _, ok := plugins[pluginName]; ok {
+				continue
+			}
+			path := filepath.Join(directory, name)
+			if !isExecutable(path) {
+				continue
+			}
+			plugins[pluginName] = path
+		}
+	}
+
+	names := []string{}
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	ret := []string{}
+	for _, name := range names {
+		ret = append(ret, plugins[name])
+	}
+	return ret
+}
+
+func pluginName(name string) string {
+	if runtime.GOOS == "windows" {
+		return strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return name
+}
+
+func isExecutable(path string) bool {
+	s, err := os.Stat(path)
+	if err != nil || s.IsDir() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	return s.Mode().Perm()&0111 != 0
+}
+
+// Describe calls the plugin at path with --describe and returns its command.
+// The provenance of the command is recorded, with the hash of the executable.
+func (r *Repository) Describe(ctx context.Context, path string) (*Command, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path, "--describe")
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return nil, errors.Wrapf(err, "could not describe plugin: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, errors.Wrap(err, "could not describe plugin")
+	}
+
+	description, err := ParseDescription(output)
+	if err != nil {
+		return nil, err
+	}
+	if description.Name == "" {
+		description.Name = pluginName(strings.TrimPrefix(filepath.Base(path), r.appName+"-"))
+	}
+
+	command, err := NewCommand(path, description, r.cmdOptions...)
+	if err != nil {
+		return nil, err
+	}
+	if content, err := os.ReadFile(path); err == nil {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			absPath = path
+		}
+		r.provenances.Record(command.Description().Source, &claycmds.Provenance{
+			Kind:     claycmds.LocationPlugin,
+			Location: filepath.Dir(path),
+			Path:     absPath,
+			LoadedAt: time.Now(),
+			Hash:     claycmds.HashContent(content),
+		})
+	}
+	return command, nil
+}
+
+// LoadCommands describes all the plugins. The plugins that can't be described are skipped,
+// see LoadErrors.
+func (r *Repository) LoadCommands(ctx context.Context) ([]cmds.Command, error) {
+	commands := []cmds.Command{}
+	r.loadErrors = []*claycmds.LoadError{}
+	r.provenances = claycmds.NewProvenances()
+	for _, path := range r.FindPlugins() {
+		command, err := r.Describe(ctx, path)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Warn().Err(err).Str("plugin", path).Msg("Could not load plugin")
+			r.loadErrors = append(r.loadErrors, claycmds.NewLoadError(path, err))
+			continue
+		}
+		commands = append(commands, command)
+	}
+	return commands, nil
+}
+
+// CollectCommands describes all the plugins and returns the commands under the given prefix.
+//
+// Errors are logged, because the repositories.Repository interface doesn't
+// allow returning them.
+func (r *Repository) CollectCommands(prefix []string, recurse bool) []cmds.Command {
+	commands, err := r.LoadCommands(context.Background())
+	if err != nil {
+		log.Warn().Err(err).Str("app", r.appName).Msg("could not load plugins")
+		return []cmds.Command{}
+	}
+
+	repository := fs.NewRepository(fs.WithCommands(commands...))
+	return repository.CollectCommands(prefix, recurse)
+}
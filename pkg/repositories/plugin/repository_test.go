@@ -0,0 +1,141 @@
+package plugin
+
+import (
+	"context"
+	claycmds "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	cmd_middlewares "github.com/go-go-golems/glazed/pkg/cmds/middlewares"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+const usersPlugin = `#!/bin/sh
+if [ "$1" = "--describe" ]; then
+  echo '{"short": "List users", "parents": ["ops"],'
+  echo ' "flags": [{"name": "limit", "type": "int", "default": 10}],'
+  echo ' "arguments": [{"name": "team", "type": "string", "required": true}]}'
+  exit 0
+fi
+read input
+echo '{"name": "alice", "id": 1}'
+echo
+echo "{\"input\": $input}"
+`
+
+func writePlugin(t *testing.T, dir string, name string, content string, mode os.FileMode) string {
+	path := filepath.Join(dir, name)
+	err := os.WriteFile(path, []byte(content), mode)
+	require.NoError(t, err)
+	return path
+}
+
+type rowCollector struct {
+	rows []types.Row
+}
+
+func (c *rowCollector) AddRow(ctx context.Context, row types.Row) error {
+	c.rows = append(c.rows, row)
+	return nil
+}
+
+func (c *rowCollector) Close(ctx context.Context) error {
+	return nil
+}
+
+func skipOnWindows(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin tests use shell scripts")
+	}
+}
+
+func TestFindPlugins(t *testing.T) {
+	skipOnWindows(t)
+	pluginDir := t.TempDir()
+	pathDir := t.TempDir()
+	t.Setenv("PATH", pathDir)
+
+	users := writePlugin(t, pluginDir, "clay-users", usersPlugin, 0755)
+	writePlugin(t, pathDir, "clay-users", "#!/bin/sh\nexit 1\n", 0755)
+	hosts := writePlugin(t, pathDir, "clay-hosts", usersPlugin, 0755)
+	writePlugin(t, pathDir, "clay-notes", "not executable", 0644)
+	writePlugin(t, pathDir, "sqleton-users", usersPlugin, 0755)
+	writePlugin(t, pathDir, "clay-", usersPlugin, 0755)
+
+	r := NewRepository("clay", WithDirectories(pluginDir))
+	assert.Equal(t, []string{hosts, users}, r.FindPlugins())
+
+	r = NewRepository("clay", WithDirectories(pluginDir), WithPath(false))
+	assert.Equal(t, []string{users}, r.FindPlugins())
+}
+
+func TestLoadAndRunPlugin(t *testing.T) {
+	skipOnWindows(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	users := writePlugin(t, dir, "clay-users", usersPlugin, 0755)
+	broken := writePlugin(t, dir, "clay-broken", "#!/bin/sh\necho 'no description' >&2\nexit 1\n", 0755)
+
+	r := NewRepository("clay", WithDirectories(dir), WithPath(false))
+	commands, err := r.LoadCommands(ctx)
+	require.NoError(t, err)
+	require.Len(t, commands, 1)
+	require.Len(t, r.LoadErrors(), 1)
+	assert.Equal(t, broken, r.LoadErrors()[0].File)
+	assert.Contains(t, r.LoadErrors()[0].Error(), "no description")
+
+	command, ok := commands[0].(*Command)
+	require.True(t, ok)
+	description := command.Description()
+	assert.Equal(t, "users", description.Name)
+	assert.Equal(t, "List users", description.Short)
+	assert.Equal(t, []string{"ops"}, description.Parents)
+	assert.Equal(t, users, description.Source)
+	provenance, ok := r.GetProvenance(command)
+	require.True(t, ok)
+	assert.Equal(t, claycmds.LocationPlugin, provenance.Kind)
+
+	assert.Len(t, r.CollectCommands([]string{"ops"}, true), 1)
+
+	parsedLayers := layers.NewParsedLayers()
+	err = cmd_middlewares.ExecuteMiddlewares(description.Layers, parsedLayers,
+		cmd_middlewares.UpdateFromMap(map[string]map[string]interface{}{
+			"default": {"team": "eng"},
+		}),
+		cmd_middlewares.SetFromDefaults(),
+	)
+	require.NoError(t, err)
+
+	gp := &rowCollector{}
+	err = command.RunIntoGlazeProcessor(ctx, parsedLayers, gp)
+	require.NoError(t, err)
+
+	rows := gp.rows
+	require.Len(t, rows, 2)
+	keys := []string{}
+	for pair := rows[0].Oldest(); pair != nil; pair = pair.Next() {
+		keys = append(keys, pair.Key)
+	}
+	assert.Equal(t, []string{"name", "id"}, keys)
+	input, ok := rows[1].Get("input")
+	require.True(t, ok)
+	assert.Equal(t, map[string]interface{}{"team": "eng", "limit": float64(10)}, input)
+}
+
+func TestFailingPlugin(t *testing.T) {
+	skipOnWindows(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := writePlugin(t, dir, "clay-fail", "#!/bin/sh\nif [ \"$1\" = \"--describe\" ]; then echo '{}'; exit 0; fi\necho 'not json'\n", 0755)
+
+	command, err := NewRepository("clay").Describe(ctx, path)
+	require.NoError(t, err)
+	assert.Equal(t, "fail", command.Description().Name)
+
+	err = command.RunIntoGlazeProcessor(ctx, layers.NewParsedLayers(), &rowCollector{})
+	assert.ErrorContains(t, err, "invalid row")
+}
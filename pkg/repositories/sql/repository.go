@@ -0,0 +1,300 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	claycmds "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/go-go-golems/clay/pkg/repositories"
+	"github.com/go-go-golems/clay/pkg/repositories/fs"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/go-go-golems/glazed/pkg/cmds/loaders"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"strings"
+	"time"
+)
+
+const DefaultTableName = "commands"
+
+const (
+	rowTypeCommand = "command"
+	rowTypeAlias   = "alias"
+)
+
+// A Repository stores commands and aliases in a single SQL table, so that a
+// command catalog can be shared across machines without syncing directories.
+//
+// Every command is stored as the YAML it was loaded from (see claycmds.CommandToYAML),
+// together with its name, parents and source. Commands are turned back into
+// glazed commands by running the stored YAML through a loaders.CommandLoader,
+// which defaults to the RawCommandLoader.
+//
+// The table can live in sqlite, mysql or postgres.
+type Repository struct {
+	db     *sqlx.DB
+	table  string
+	loader loaders.CommandLoader
+	// these options are passed to the loader to create new descriptions
+	cmdOptions []cmds.CommandDescriptionOption
+}
+
+var _ repositories.Repository = (*Repository)(nil)
+
+type RepositoryOption func(*Repository)
+
+// WithLoader sets the command loader used to turn the stored YAML back into commands.
+func WithLoader(loader loaders.CommandLoader) RepositoryOption {
+	return func(r *Repository) {
+		r.loader = loader
+	}
+}
+
+// WithTable sets the name of the table the commands are stored in.
+func WithTable(table string) RepositoryOption {
+	return func(r *Repository) {
+		r.table = table
+	}
+}
+
+func WithCommandDescriptionOptions(cmdOptions []cmds.CommandDescriptionOption) RepositoryOption {
+	return func(r *Repository) {
+		r.cmdOptions = cmdOptions
+	}
+}
+
+// NewRepository creates a new repository backed by the given database.
+// Call CreateTables to make sure the table exists.
+func NewRepository(db *sqlx.DB, options ...RepositoryOption) *Repository {
+	ret := &Repository{
+		db:     db,
+		table:  DefaultTableName,
+		loader: claycmds.NewRawCommandLoader(),
+	}
+	for _, opt := range options {
+		opt(ret)
+	}
+	return ret
+}
+
+type commandRow struct {
+	Path     string `db:"path"`
+	Name     string `db:"name"`
+	Parents  string `db:"parents"`
+	Type     string `db:"type"`
+	AliasFor string `db:"alias_for"`
+	Source   string `db:"source"`
+	YAML     string `db:"yaml"`
+}
+
+func (c *commandRow) GetParents() []string {
+	if c.Parents == "" {
+		return []string{}
+	}
+	return strings.Split(c.Parents, "/")
+}
+
+// CreateTables creates the command table if it doesn't exist yet.
+func (r *Repository) CreateTables(ctx context.Context) error {
+	// mysql can't store large YAML files in a TEXT column
+	yamlType := "TEXT"
+	if r.db.DriverName() == "mysql" {
+		yamlType = "MEDIUMTEXT"
+	}
+
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	path VARCHAR(255) NOT NULL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	parents VARCHAR(255) NOT NULL,
+	type VARCHAR(16) NOT NULL,
+	alias_for VARCHAR(255) NOT NULL,
+	source TEXT NOT NULL,
+	yaml %s NOT NULL
+)`, r.table, yamlType)
+
+	_, err := r.db.ExecContext(ctx, query)
+	if err != nil {
+		return errors.Wrapf(err, "could not create table %s", r.table)
+	}
+	return nil
+}
+
+func commandToRow(command cmds.Command) (*commandRow, error) {
+	ret := &commandRow{
+		Type: rowTypeCommand,
+	}
+
+	// the description of an alias is only available once it has been resolved,
+	// so we read the fields straight from the alias.
+	if alias_, ok := command.(*alias.CommandAlias); ok {
+		ret.Type = rowTypeAlias
+		ret.Name = alias_.Name
+		ret.Parents = strings.Join(alias_.Parents, "/")
+		ret.AliasFor = alias_.AliasFor
+		ret.Source = alias_.Source
+	} else {
+		description := command.Description()
+		ret.Name = description.Name
+		ret.Parents = strings.Join(description.Parents, "/")
+		ret.Source = description.Source
+	}
+
+	if ret.Parents == "" {
+		ret.Path = ret.Name
+	} else {
+		ret.Path = ret.Parents + "/" + ret.Name
+	}
+
+	yaml, err := claycmds.CommandToYAML(command)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not serialize command %s", ret.Path)
+	}
+	ret.YAML = string(yaml)
+
+	return ret, nil
+}
+
+// Add stores the given commands and aliases, replacing existing entries with the same path.
+func (r *Repository) Add(ctx context.Context, commands ...cmds.Command) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	deleteQuery := tx.Rebind(fmt.Sprintf("DELETE FROM %s WHERE path = ?", r.table))
+	insertQuery := fmt.Sprintf(
+		`INSERT INTO %s (path, name, parents, type, alias_for, source, yaml)
+VALUES (:path, :name, :parents, :type, :alias_for, :source, :yaml)`,
+		r.table)
+
+	for _, command := range commands {
+		row, err := commandToRow(command)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, deleteQuery, row.Path)
+		if err != nil {
+			return errors.Wrapf(err, "could not replace command %s", row.Path)
+		}
+		_, err = tx.NamedExecContext(ctx, insertQuery, row)
+		if err != nil {
+			return errors.Wrapf(err, "could not insert command %s", row.Path)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Remove deletes the commands and aliases at the given prefixes, as well as everything below them.
+func (r *Repository) Remove(ctx context.Context, prefixes ...[]string) error {
+	rows, err := r.loadRows(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	deleteQuery := tx.Rebind(fmt.Sprintf("DELETE FROM %s WHERE path = ?", r.table))
+	for _, prefix := range prefixes {
+		path := strings.Join(prefix, "/")
+		for _, row := range rows {
+			if path != "" && row.Path != path && !strings.HasPrefix(row.Path, path+"/") {
+				continue
+			}
+			_, err = tx.ExecContext(ctx, deleteQuery, row.Path)
+			if err != nil {
+				return errors.Wrapf(err, "could not remove command %s", row.Path)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repository) loadRows(ctx context.Context) ([]*commandRow, error) {
+	rows := []*commandRow{}
+	err := r.db.SelectContext(ctx, &rows,
+		fmt.Sprintf("SELECT path, name, parents, type, alias_for, source, yaml FROM %s ORDER BY path", r.table))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not load commands from %s", r.table)
+	}
+	return rows, nil
+}
+
+// LoadCommands loads all commands and aliases stored in the database.
+// Aliases are returned unresolved, use an fs.Repository (or CollectCommands) to resolve them.
+func (r *Repository) LoadCommands(ctx context.Context) ([]cmds.Command, error) {
+	rows, err := r.loadRows(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []cmds.Command{}
+	for _, row := range rows {
+		parents := row.GetParents()
+
+		// aliases are not specific to a loader, so we don't rely on the loader to recognize them
+		if row.Type == rowTypeAlias {
+			aliases, err := loaders.LoadCommandAliasFromYAML(
+				strings.NewReader(row.YAML),
+				alias.WithSource(row.Source),
+				alias.WithParents(parents...),
+			)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not load alias %s", row.Path)
+			}
+			for _, alias_ := range aliases {
+				ret = append(ret, alias_)
+			}
+			continue
+		}
+
+		fileName := row.Name + ".yaml"
+		f := claycmds.NewMemoryFS()
+		f.AddFile(fileName, []byte(row.YAML), time.Time{})
+
+		options_ := append([]cmds.CommandDescriptionOption{
+			cmds.WithSource(row.Source),
+			cmds.WithParents(parents...),
+		}, r.cmdOptions...)
+		aliasOptions := []alias.Option{
+			alias.WithSource(row.Source),
+			alias.WithParents(parents...),
+		}
+
+		commands, err := r.loader.LoadCommands(f, fileName, options_, aliasOptions)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not load command %s", row.Path)
+		}
+		ret = append(ret, commands...)
+	}
+
+	return ret, nil
+}
+
+// CollectCommands loads all commands from the database into an in-memory
+// repository and returns the commands under the given prefix.
+//
+// Errors are logged, because the repositories.Repository interface doesn't
+// allow returning them.
+func (r *Repository) CollectCommands(prefix []string, recurse bool) []cmds.Command {
+	commands, err := r.LoadCommands(context.Background())
+	if err != nil {
+		log.Warn().Err(err).Msg("could not load commands from database")
+		return []cmds.Command{}
+	}
+
+	repository := fs.NewRepository(fs.WithCommands(commands...))
+	return repository.CollectCommands(prefix, recurse)
+}
@@ -0,0 +1,147 @@
+package sql
+
+import (
+	"context"
+	claycmds "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func openTestRepository(t *testing.T) *Repository {
+	db, err := sqlx.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	// every connection to :memory: opens a new database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	r := NewRepository(db)
+	err = r.CreateTables(context.Background())
+	require.NoError(t, err)
+	return r
+}
+
+func loadTestCommands(t *testing.T, files map[string]string) []cmds.Command {
+	f := fstest.MapFS{}
+	for name, content := range files {
+		f[name] = &fstest.MapFile{Data: []byte(content)}
+	}
+
+	loader := claycmds.NewRawCommandLoader()
+	ret := []cmds.Command{}
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parents := strings.Split(name, "/")
+		parents = parents[:len(parents)-1]
+		commands, err := loader.LoadCommands(f, name,
+			[]cmds.CommandDescriptionOption{cmds.WithParents(parents...), cmds.WithSource(name)},
+			[]alias.Option{alias.WithParents(parents...), alias.WithSource(name)},
+		)
+		require.NoError(t, err)
+		ret = append(ret, commands...)
+	}
+	return ret
+}
+
+func getFullNames(commands []cmds.Command) []string {
+	ret := []string{}
+	for _, c := range commands {
+		d := c.Description()
+		ret = append(ret, strings.Join(append(d.Parents, d.Name), " "))
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func TestAddAndCollectCommands(t *testing.T) {
+	r := openTestRepository(t)
+	ctx := context.Background()
+
+	commands := loadTestCommands(t, map[string]string{
+		"ls.yaml":        "name: ls\nshort: List things\n",
+		"ops/users.yaml": "name: users\nshort: List users\n",
+	})
+	commands = append(commands, alias.NewCommandAlias(
+		alias.WithName("all"),
+		alias.WithAliasFor("users"),
+		alias.WithParents("ops", "users"),
+		alias.WithFlags(map[string]string{"limit": "10"}),
+	))
+
+	err := r.Add(ctx, commands...)
+	require.NoError(t, err)
+
+	collected := r.CollectCommands([]string{}, true)
+	assert.Equal(t, []string{"ls", "ops users", "ops users all"}, getFullNames(collected))
+
+	collected = r.CollectCommands([]string{"ops"}, true)
+	assert.Equal(t, []string{"ops users", "ops users all"}, getFullNames(collected))
+
+	for _, c := range collected {
+		switch v := c.(type) {
+		case *alias.CommandAlias:
+			assert.Equal(t, "users", v.AliasFor)
+			assert.Equal(t, map[string]string{"limit": "10"}, v.Flags)
+			require.NotNil(t, v.AliasedCommand)
+			assert.Equal(t, "users", v.AliasedCommand.Description().Name)
+		case *claycmds.RawCommand:
+			assert.Equal(t, "List users", v.Short)
+			assert.Equal(t, "ops/users.yaml", v.Source)
+			assert.Equal(t, "users", v.YAMLContent["name"])
+		default:
+			t.Fatalf("unexpected command type %T", v)
+		}
+	}
+}
+
+func TestAddReplacesCommand(t *testing.T) {
+	r := openTestRepository(t)
+	ctx := context.Background()
+
+	err := r.Add(ctx, loadTestCommands(t, map[string]string{
+		"ls.yaml": "name: ls\nshort: List things\n",
+	})...)
+	require.NoError(t, err)
+	err = r.Add(ctx, loadTestCommands(t, map[string]string{
+		"ls.yaml": "name: ls\nshort: List other things\n",
+	})...)
+	require.NoError(t, err)
+
+	collected := r.CollectCommands([]string{"ls"}, false)
+	require.Len(t, collected, 1)
+	assert.Equal(t, "List other things", collected[0].Description().Short)
+}
+
+func TestRemoveCommands(t *testing.T) {
+	r := openTestRepository(t)
+	ctx := context.Background()
+
+	err := r.Add(ctx, loadTestCommands(t, map[string]string{
+		"ls.yaml":            "name: ls\nshort: List things\n",
+		"ops/users.yaml":     "name: users\nshort: List users\n",
+		"ops/users/all.yaml": "name: all\nshort: All users\n",
+		"ops/db/size.yaml":   "name: size\nshort: Database size\n",
+	})...)
+	require.NoError(t, err)
+
+	err = r.Remove(ctx, []string{"ops", "users"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ls", "ops db size"}, getFullNames(r.CollectCommands([]string{}, true)))
+
+	err = r.Remove(ctx, []string{"ops"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ls"}, getFullNames(r.CollectCommands([]string{}, true)))
+}
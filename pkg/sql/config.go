@@ -1,14 +1,19 @@
 package sql
 
 import (
+	"context"
 	"fmt"
 	"github.com/go-go-golems/glazed/pkg/cmds/layers"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type DatabaseConfig struct {
@@ -24,6 +29,15 @@ type DatabaseConfig struct {
 	DbtProfilesPath string `glazed.parameter:"dbt-profiles-path"`
 	DbtProfile      string `glazed.parameter:"dbt-profile"`
 	UseDbtProfiles  bool   `glazed.parameter:"use-dbt-profiles"`
+	ReadOnly        bool   `glazed.parameter:"read-only"`
+	// connection pool settings, 0 keeps the default of database/sql
+	MaxOpenConnections int `glazed.parameter:"max-open-connections"`
+	MaxIdleConnections int `glazed.parameter:"max-idle-connections"`
+	// timeouts and durations in seconds, 0 means no limit
+	ConnectionMaxLifetime int `glazed.parameter:"connection-max-lifetime"`
+	ConnectionMaxIdleTime int `glazed.parameter:"connection-max-idle-time"`
+	ConnectTimeout        int `glazed.parameter:"connect-timeout"`
+	StatementTimeout      int `glazed.parameter:"statement-timeout"`
 }
 
 // LogVerbose just outputs information about the database config to the
@@ -126,6 +140,12 @@ func (c *DatabaseConfig) GetSource() (*Source, error) {
 	return source, nil
 }
 
+// Connect opens the database and configures its connection pool.
+//
+// If ReadOnly is set, the connection is opened read-only for the database driver, and the
+// queries run on it are checked with CheckReadOnlyQuery. The StatementTimeout is applied to
+// the queries run with RunQueryIntoGlaze and RunNamedQueryIntoGlaze, see WithStatementTimeout.
+// The ConnectTimeout is passed to the driver in the connection string.
 func (c *DatabaseConfig) Connect() (*sqlx.DB, error) {
 	c.LogVerbose()
 
@@ -144,12 +164,99 @@ func (c *DatabaseConfig) Connect() (*sqlx.DB, error) {
 		connectionString = s.ToConnectionString()
 
 	}
-	db, err := sqlx.Connect(dbType, connectionString)
+	if c.ReadOnly {
+		log.Debug().Str("type", dbType).Msg("Using read-only connection")
+		var err error
+		connectionString, err = readOnlyConnectionString(dbType, connectionString)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ctx := context.Background()
+	if c.ConnectTimeout > 0 {
+		var err error
+		connectionString, err = connectTimeoutConnectionString(dbType, connectionString, c.ConnectTimeout)
+		if err != nil {
+			return nil, err
+		}
+		// the connection string applies the timeout to every connection of the pool,
+		// the context bounds the first connection for the drivers that don't support it
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(c.ConnectTimeout)*time.Second)
+		defer cancel()
+	}
+	db, err := openDatabase(dbType, connectionString, &connectionSettings{
+		readOnly:         c.ReadOnly,
+		statementTimeout: time.Duration(c.StatementTimeout) * time.Second,
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = db.PingContext(ctx)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	if c.MaxOpenConnections > 0 {
+		db.SetMaxOpenConns(c.MaxOpenConnections)
+	}
+	if c.MaxIdleConnections > 0 {
+		db.SetMaxIdleConns(c.MaxIdleConnections)
+	}
+	if c.ConnectionMaxLifetime > 0 {
+		db.SetConnMaxLifetime(time.Duration(c.ConnectionMaxLifetime) * time.Second)
+	}
+	if c.ConnectionMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(time.Duration(c.ConnectionMaxIdleTime) * time.Second)
+	}
 
-	// TODO(2022-12-18, manuel): this is where we would add support for a ro connection
-	// https://github.com/wesen/sqleton/issues/24
+	return db, nil
+}
 
-	return db, err
+// connectTimeoutConnectionString adds the connect timeout, in seconds, to the connection string
+// of driver, so that it applies to every connection opened by the pool:
+//   - mysql sets the dial timeout
+//   - postgres sets connect_timeout
+//   - sqlite3 has no connection to wait for, and sets the busy timeout instead, which is how long
+//     to wait for a locked database
+//
+// The connection string of other drivers is returned unchanged.
+func connectTimeoutConnectionString(driver string, connectionString string, timeout int) (string, error) {
+	switch driver {
+	case "sqlite", "sqlite3":
+		param := fmt.Sprintf("_busy_timeout=%d", timeout*1000)
+		if strings.Contains(connectionString, "?") {
+			return connectionString + "&" + param, nil
+		}
+		return connectionString + "?" + param, nil
+
+	case "postgres":
+		if strings.HasPrefix(connectionString, "postgres://") || strings.HasPrefix(connectionString, "postgresql://") {
+			u, err := url.Parse(connectionString)
+			if err != nil {
+				return "", errors.Wrap(err, "could not parse postgres connection string")
+			}
+			q := u.Query()
+			q.Set("connect_timeout", strconv.Itoa(timeout))
+			u.RawQuery = q.Encode()
+			return u.String(), nil
+		}
+		return strings.TrimSpace(fmt.Sprintf("%s connect_timeout=%d", connectionString, timeout)), nil
+
+	case "mysql":
+		cfg, err := mysql.ParseDSN(connectionString)
+		if err != nil {
+			return "", errors.Wrap(err, "could not parse mysql connection string")
+		}
+		cfg.Timeout = time.Duration(timeout) * time.Second
+		return cfg.FormatDSN(), nil
+
+	default:
+		log.Debug().Str("driver", driver).Msg("Connect timeout only applies to the first connection")
+		return connectionString, nil
+	}
 }
 
 func NewConfigFromParsedLayers(parsedLayers ...*layers.ParsedLayer) (*DatabaseConfig, error) {
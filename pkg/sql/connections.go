@@ -0,0 +1,107 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+// connectionSettings are the settings of a connection opened by DatabaseConfig.Connect
+// that are applied when running queries on it.
+//
+// The settings are carried by the driver of the connection (see settingsDriver), so that
+// they can be looked up from a *sqlx.DB and go away with it.
+type connectionSettings struct {
+	readOnly         bool
+	statementTimeout time.Duration
+}
+
+// settingsDriver is the driver returned by the Driver method of the connections opened
+// with openDatabase. It delegates everything to the registered driver.
+type settingsDriver struct {
+	driver.Driver
+	settings *connectionSettings
+}
+
+// settingsConnector opens connections with the connector of the registered driver,
+// and returns the settingsDriver as its driver.
+type settingsConnector struct {
+	connector driver.Connector
+	driver    *settingsDriver
+}
+
+func (c *settingsConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.connector.Connect(ctx)
+}
+
+func (c *settingsConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// dsnConnector is the connector of the drivers that don't implement driver.DriverContext.
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c *dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c *dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// openDatabase opens the database like sqlx.Open, with settings attached to the connection.
+func openDatabase(driverName string, dsn string, settings *connectionSettings) (*sqlx.DB, error) {
+	// sql.Open doesn't connect, it is only used to look up the registered driver
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	driver_ := db.Driver()
+	_ = db.Close()
+
+	var connector driver.Connector = &dsnConnector{dsn: dsn, driver: driver_}
+	if driverContext, ok := driver_.(driver.DriverContext); ok {
+		connector, err = driverContext.OpenConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sqlx.NewDb(sql.OpenDB(&settingsConnector{
+		connector: connector,
+		driver:    &settingsDriver{Driver: driver_, settings: settings},
+	}), driverName), nil
+}
+
+func getConnectionSettings(db *sqlx.DB) (*connectionSettings, bool) {
+	d, ok := db.Driver().(*settingsDriver)
+	if !ok {
+		return nil, false
+	}
+	return d.settings, true
+}
+
+// StatementTimeout returns the statement timeout db was opened with, 0 if there is none.
+func StatementTimeout(db *sqlx.DB) time.Duration {
+	s, ok := getConnectionSettings(db)
+	if !ok {
+		return 0
+	}
+	return s.statementTimeout
+}
+
+// WithStatementTimeout returns a context that is canceled after the statement timeout of db.
+// It is used by RunQueryIntoGlaze and RunNamedQueryIntoGlaze. Callers of RunQuery should use it
+// as well, since the returned rows are read after RunQuery returns.
+func WithStatementTimeout(ctx context.Context, db *sqlx.DB) (context.Context, context.CancelFunc) {
+	timeout := StatementTimeout(db)
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
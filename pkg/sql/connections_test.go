@@ -0,0 +1,126 @@
+package sql
+
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestConnectionPoolSettings(t *testing.T) {
+	path := createTestSqliteDatabase(t)
+
+	db, err := (&DatabaseConfig{
+		DSN:                   path,
+		Driver:                "sqlite3",
+		MaxOpenConnections:    3,
+		MaxIdleConnections:    1,
+		ConnectionMaxLifetime: 60,
+		ConnectionMaxIdleTime: 10,
+		ConnectTimeout:        5,
+	}).Connect()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+
+	assert.Equal(t, 3, db.Stats().MaxOpenConnections)
+	assert.Equal(t, time.Duration(0), StatementTimeout(db))
+
+	gp := &rowCollector{}
+	err = RunQueryIntoGlaze(context.Background(), db, "SELECT name FROM users", []interface{}{}, gp)
+	require.NoError(t, err)
+	assert.Len(t, gp.rows, 2)
+}
+
+func TestStatementTimeout(t *testing.T) {
+	path := createTestSqliteDatabase(t)
+
+	db, err := (&DatabaseConfig{
+		DSN:              path,
+		Driver:           "sqlite3",
+		StatementTimeout: 1,
+	}).Connect()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	assert.Equal(t, time.Second, StatementTimeout(db))
+	assert.False(t, IsReadOnly(db))
+
+	start := time.Now()
+	err = RunQueryIntoGlaze(
+		context.Background(), db,
+		"WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c) SELECT count(*) FROM c",
+		[]interface{}{}, &rowCollector{},
+	)
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 10*time.Second)
+
+	// the connection is still usable after the timeout
+	gp := &rowCollector{}
+	err = RunQueryIntoGlaze(context.Background(), db, "SELECT name FROM users", []interface{}{}, gp)
+	require.NoError(t, err)
+	assert.Len(t, gp.rows, 2)
+}
+
+func TestConnectionSettingsArePerConnection(t *testing.T) {
+	path := createTestSqliteDatabase(t)
+
+	readOnly, err := (&DatabaseConfig{DSN: path, Driver: "sqlite3", ReadOnly: true, StatementTimeout: 2}).Connect()
+	require.NoError(t, err)
+	defer func() {
+		_ = readOnly.Close()
+	}()
+	readWrite, err := (&DatabaseConfig{DSN: path, Driver: "sqlite3"}).Connect()
+	require.NoError(t, err)
+	defer func() {
+		_ = readWrite.Close()
+	}()
+	plain, err := sqlx.Connect("sqlite3", path)
+	require.NoError(t, err)
+	defer func() {
+		_ = plain.Close()
+	}()
+
+	assert.True(t, IsReadOnly(readOnly))
+	assert.Equal(t, 2*time.Second, StatementTimeout(readOnly))
+	assert.False(t, IsReadOnly(readWrite))
+	assert.Equal(t, time.Duration(0), StatementTimeout(readWrite))
+	assert.False(t, IsReadOnly(plain))
+	assert.Equal(t, time.Duration(0), StatementTimeout(plain))
+}
+
+func TestConnectTimeoutConnectionString(t *testing.T) {
+	tests := []struct {
+		driver   string
+		input    string
+		expected string
+	}{
+		{"sqlite3", "/tmp/test.db", "/tmp/test.db?_busy_timeout=5000"},
+		{"sqlite3", "file:/tmp/test.db?mode=ro&_query_only=1", "file:/tmp/test.db?mode=ro&_query_only=1&_busy_timeout=5000"},
+		{
+			"postgres",
+			"host=localhost port=5432 user=u password=p dbname=db sslmode=disable",
+			"host=localhost port=5432 user=u password=p dbname=db sslmode=disable connect_timeout=5",
+		},
+		{
+			"postgres",
+			"postgres://u:p@localhost:5432/db?sslmode=disable",
+			"postgres://u:p@localhost:5432/db?connect_timeout=5&sslmode=disable",
+		},
+		{
+			"mysql",
+			"u:p@tcp(localhost:3306)/db",
+			"u:p@tcp(localhost:3306)/db?timeout=5s",
+		},
+		{"oracle", "db", "db"},
+	}
+	for _, tt := range tests {
+		s, err := connectTimeoutConnectionString(tt.driver, tt.input, 5)
+		require.NoError(t, err, tt.input)
+		assert.Equal(t, tt.expected, s)
+	}
+}
@@ -16,11 +16,22 @@ func RunQueryIntoGlaze(
 	parameters []interface{},
 	gp middlewares.Processor) error {
 
+	err := checkQuery(db, query)
+	if err != nil {
+		return err
+	}
+
+	dbContext, cancel := WithStatementTimeout(dbContext, db)
+	defer cancel()
+
 	// use a prepared statement so that when using mysql, we get native types back
 	stmt, err := db.PreparexContext(dbContext, query)
 	if err != nil {
 		return errors.Wrapf(err, "Could not prepare query: %s", query)
 	}
+	defer func() {
+		_ = stmt.Close()
+	}()
 
 	rows, err := stmt.QueryxContext(dbContext, parameters...)
 	if err != nil {
@@ -37,11 +48,22 @@ func RunNamedQueryIntoGlaze(
 	parameters map[string]interface{},
 	gp middlewares.Processor) error {
 
+	err := checkQuery(db, query)
+	if err != nil {
+		return err
+	}
+
+	dbContext, cancel := WithStatementTimeout(dbContext, db)
+	defer cancel()
+
 	// use a statement so that when using mysql, we get native types back
 	stmt, err := db.PrepareNamedContext(dbContext, query)
 	if err != nil {
 		return errors.Wrapf(err, "Could not prepare query: %s", query)
 	}
+	defer func() {
+		_ = stmt.Close()
+	}()
 
 	rows, err := stmt.QueryxContext(dbContext, parameters)
 	if err != nil {
@@ -52,6 +74,11 @@ func RunNamedQueryIntoGlaze(
 }
 
 func processQueryResults(ctx context.Context, rows *sqlx.Rows, gp middlewares.Processor) error {
+	// release the connection when returning early
+	defer func(rows *sqlx.Rows) {
+		_ = rows.Close()
+	}(rows)
+
 	// we need a way to order the columns
 	cols, err := rows.Columns()
 	if err != nil {
@@ -83,9 +110,17 @@ func processQueryResults(ctx context.Context, rows *sqlx.Rows, gp middlewares.Pr
 		}
 	}
 
+	// the query was interrupted, for example by the statement timeout
+	err = rows.Err()
+	if err != nil {
+		return errors.Wrapf(err, "Could not read rows")
+	}
+
 	return nil
 }
 
+// RunQuery renders the query template and runs it. The rows are read with ctx, which should
+// be created with WithStatementTimeout to apply the statement timeout of db.
 func RunQuery(
 	ctx context.Context,
 	subQueries map[string]string,
@@ -126,6 +161,11 @@ func RunQuery(
 		return query_, nil, err
 	}
 
+	err = checkQuery(db, query_)
+	if err != nil {
+		return query_, nil, err
+	}
+
 	stmt, err := db.PreparexContext(ctx, query_)
 	if err != nil {
 		return query_, nil, err
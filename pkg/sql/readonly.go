@@ -0,0 +1,210 @@
+package sql
+
+import (
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// Read-only connections are enforced twice: the connection string is changed so that the
+// database itself refuses writes (see readOnlyConnectionString), and the queries run with
+// RunQueryIntoGlaze, RunNamedQueryIntoGlaze and RunQuery are checked with CheckReadOnlyQuery
+// before they are sent to the database.
+
+// ErrMutatingStatement is returned when running a statement that modifies the database
+// on a read-only connection.
+var ErrMutatingStatement = errors.New("statement not allowed on a read-only connection")
+
+// mutatingKeywords are the first keywords of the statements that modify the database,
+// or that can't be checked, such as procedure calls
+var mutatingKeywords = map[string]bool{
+	"INSERT":   true,
+	"UPDATE":   true,
+	"DELETE":   true,
+	"MERGE":    true,
+	"REPLACE":  true,
+	"UPSERT":   true,
+	"CREATE":   true,
+	"ALTER":    true,
+	"DROP":     true,
+	"TRUNCATE": true,
+	"RENAME":   true,
+	"GRANT":    true,
+	"REVOKE":   true,
+	"COPY":     true,
+	"CALL":     true,
+	"EXEC":     true,
+	"EXECUTE":  true,
+	"LOAD":     true,
+	"ATTACH":   true,
+	"DETACH":   true,
+	"VACUUM":   true,
+	"REINDEX":  true,
+	"LOCK":     true,
+	"COMMENT":  true,
+}
+
+// modeKeywords are the first keywords of the statements that can turn off the read-only mode
+// of the connection: SET, PRAGMA, BEGIN and START TRANSACTION.
+var modeKeywords = map[string]bool{
+	"SET":    true,
+	"PRAGMA": true,
+	"BEGIN":  true,
+	"START":  true,
+}
+
+// IsReadOnly returns true if db was opened with the read-only flag.
+func IsReadOnly(db *sqlx.DB) bool {
+	s, ok := getConnectionSettings(db)
+	return ok && s.readOnly
+}
+
+// checkQuery checks query with CheckReadOnlyQuery if db is read-only.
+func checkQuery(db *sqlx.DB, query string) error {
+	if !IsReadOnly(db) {
+		return nil
+	}
+	return CheckReadOnlyQuery(query)
+}
+
+// CheckReadOnlyQuery returns ErrMutatingStatement if one of the statements of query obviously
+// modifies the database, or turns off the read-only mode of the connection.
+//
+// This only looks at the keywords of the statements, outside of comments and quotes, and doesn't
+// catch everything (for example functions with side effects), which is why the read-only mode
+// is also enforced by the database.
+func CheckReadOnlyQuery(query string) error {
+	for _, statement := range splitStatements(query) {
+		if len(statement) == 0 {
+			continue
+		}
+
+		keyword := statement[0]
+		if mutatingKeywords[keyword] {
+			return errors.Wrapf(ErrMutatingStatement, "%s statement", keyword)
+		}
+		for i, word := range statement {
+			// data-modifying statements in a WITH clause
+			if keyword == "WITH" && (word == "INSERT" || word == "UPDATE" || word == "DELETE" || word == "MERGE") {
+				return errors.Wrapf(ErrMutatingStatement, "%s statement", word)
+			}
+			// SET default_transaction_read_only = off, PRAGMA query_only = 0, BEGIN READ WRITE, ...
+			// Other statements, such as SELECT is_read_only FROM sys.databases, only read the mode.
+			if modeKeywords[keyword] &&
+				(strings.HasSuffix(word, "READ_ONLY") || word == "QUERY_ONLY" ||
+					(word == "READ" && i+1 < len(statement) && statement[i+1] == "WRITE")) {
+				return errors.Wrapf(ErrMutatingStatement, "%s statement changing the read-only mode", keyword)
+			}
+		}
+	}
+	return nil
+}
+
+// splitStatements splits query into statements at semicolons, and returns the uppercased
+// words of every statement, skipping comments and quoted strings and identifiers.
+func splitStatements(query string) [][]string {
+	ret := [][]string{}
+	statement := []string{}
+	word := strings.Builder{}
+
+	endWord := func() {
+		if word.Len() > 0 {
+			statement = append(statement, strings.ToUpper(word.String()))
+			word.Reset()
+		}
+	}
+
+	runes := []rune(query)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			endWord()
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			endWord()
+			i += 2
+			for i < len(runes) && !(runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/') {
+				i++
+			}
+			i++
+		case c == '\'' || c == '"' || c == '`':
+			endWord()
+			i++
+			for i < len(runes) && runes[i] != c {
+				if runes[i] == '\\' {
+					i++
+				}
+				i++
+			}
+		case c == ';':
+			endWord()
+			ret = append(ret, statement)
+			statement = []string{}
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_':
+			word.WriteRune(c)
+		default:
+			endWord()
+		}
+	}
+	endWord()
+	return append(ret, statement)
+}
+
+// readOnlyConnectionString changes the connection string of driver so that the database
+// refuses writes:
+//   - sqlite3 opens the file read-only, with query_only set
+//   - postgres sets default_transaction_read_only for the session
+//   - mysql sets transaction_read_only for the session
+func readOnlyConnectionString(driver string, connectionString string) (string, error) {
+	switch driver {
+	case "sqlite", "sqlite3":
+		dsn := connectionString
+		if !strings.HasPrefix(dsn, "file:") {
+			dsn = "file:" + dsn
+		}
+		params := "mode=ro&_query_only=1"
+		if strings.Contains(dsn, ":memory:") || strings.Contains(dsn, "mode=memory") {
+			// in-memory databases can't be opened read-only
+			params = "_query_only=1"
+		}
+		if strings.Contains(dsn, "?") {
+			return dsn + "&" + params, nil
+		}
+		return dsn + "?" + params, nil
+
+	case "postgres":
+		if strings.HasPrefix(connectionString, "postgres://") || strings.HasPrefix(connectionString, "postgresql://") {
+			u, err := url.Parse(connectionString)
+			if err != nil {
+				return "", errors.Wrap(err, "could not parse postgres connection string")
+			}
+			q := u.Query()
+			q.Set("default_transaction_read_only", "on")
+			u.RawQuery = q.Encode()
+			return u.String(), nil
+		}
+		// parameters unknown to the driver are sent to the server as run-time parameters
+		return strings.TrimSpace(connectionString + " default_transaction_read_only=on"), nil
+
+	case "mysql":
+		cfg, err := mysql.ParseDSN(connectionString)
+		if err != nil {
+			return "", errors.Wrap(err, "could not parse mysql connection string")
+		}
+		if cfg.Params == nil {
+			cfg.Params = map[string]string{}
+		}
+		// parameters unknown to the driver are set as system variables when connecting
+		cfg.Params["transaction_read_only"] = "1"
+		return cfg.FormatDSN(), nil
+
+	default:
+		return "", errors.Errorf("read-only connections are not supported for driver %s", driver)
+	}
+}
@@ -0,0 +1,184 @@
+package sql
+
+import (
+	"context"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+type rowCollector struct {
+	rows []types.Row
+}
+
+func (c *rowCollector) AddRow(ctx context.Context, row types.Row) error {
+	c.rows = append(c.rows, row)
+	return nil
+}
+
+func (c *rowCollector) Close(ctx context.Context) error {
+	return nil
+}
+
+func TestCheckReadOnlyQuery(t *testing.T) {
+	tests := []struct {
+		query    string
+		mutating bool
+	}{
+		{"SELECT * FROM users", false},
+		{"select name from users where status = 'deleted'", false},
+		{"  -- DELETE the cache\n SELECT 1", false},
+		{"/* DROP TABLE users; */ SELECT 1", false},
+		{"SELECT 'x; DROP TABLE users'", false},
+		{"SELECT updated_at, \"delete\" FROM users", false},
+		{"EXPLAIN SELECT * FROM users", false},
+		{"SHOW TABLES", false},
+		{"WITH u AS (SELECT * FROM users) SELECT * FROM u", false},
+		{"SET search_path TO analytics", false},
+		{"SELECT * FROM users;", false},
+		{"SELECT is_read_only FROM sys.databases", false},
+		{"SHOW default_transaction_read_only", false},
+		{"SELECT @@transaction_read_only", false},
+		{"PRAGMA table_info(users)", false},
+		{"BEGIN READ ONLY", false},
+		{"insert into users (name) values ('a')", true},
+		{"UPDATE users SET name = 'a'", true},
+		{"DELETE FROM users", true},
+		{"SELECT 1; DROP TABLE users", true},
+		{"/* cleanup */ TRUNCATE users", true},
+		{"CREATE TABLE t (id int)", true},
+		{"ALTER TABLE users ADD COLUMN age int", true},
+		{"WITH d AS (DELETE FROM users RETURNING *) SELECT * FROM d", true},
+		{"SET default_transaction_read_only = off", true},
+		{"SET SESSION transaction_read_only = 0", true},
+		{"PRAGMA query_only = 0", true},
+		{"BEGIN READ WRITE", true},
+		{"COPY users FROM '/tmp/users.csv'", true},
+		{"CALL refresh_users()", true},
+		{"EXEC sp_refresh_users", true},
+		{"EXECUTE delete_users", true},
+		{"LOAD DATA INFILE 'users.csv' INTO TABLE users", true},
+		{"ATTACH DATABASE 'other.db' AS other", true},
+		{"DETACH DATABASE other", true},
+		{"VACUUM", true},
+		{"REINDEX TABLE users", true},
+		{"LOCK TABLES users WRITE", true},
+		{"COMMENT ON TABLE users IS 'people'", true},
+		{"START TRANSACTION READ WRITE", true},
+		{"SET TRANSACTION READ WRITE", true},
+	}
+	for _, tt := range tests {
+		err := CheckReadOnlyQuery(tt.query)
+		if tt.mutating {
+			assert.True(t, errors.Is(err, ErrMutatingStatement), tt.query)
+		} else {
+			assert.NoError(t, err, tt.query)
+		}
+	}
+}
+
+func TestReadOnlyConnectionString(t *testing.T) {
+	tests := []struct {
+		driver   string
+		input    string
+		expected string
+	}{
+		{"sqlite3", "/tmp/test.db", "file:/tmp/test.db?mode=ro&_query_only=1"},
+		{"sqlite3", "file:test.db?cache=shared", "file:test.db?cache=shared&mode=ro&_query_only=1"},
+		{"sqlite3", ":memory:", "file::memory:?_query_only=1"},
+		{
+			"postgres",
+			"host=localhost port=5432 user=u password=p dbname=db sslmode=disable",
+			"host=localhost port=5432 user=u password=p dbname=db sslmode=disable default_transaction_read_only=on",
+		},
+		{
+			"postgres",
+			"postgres://u:p@localhost:5432/db?sslmode=disable",
+			"postgres://u:p@localhost:5432/db?default_transaction_read_only=on&sslmode=disable",
+		},
+		{
+			"mysql",
+			"u:p@tcp(localhost:3306)/db",
+			"u:p@tcp(localhost:3306)/db?transaction_read_only=1",
+		},
+	}
+	for _, tt := range tests {
+		s, err := readOnlyConnectionString(tt.driver, tt.input)
+		require.NoError(t, err, tt.input)
+		assert.Equal(t, tt.expected, s)
+	}
+
+	_, err := readOnlyConnectionString("oracle", "db")
+	assert.Error(t, err)
+}
+
+func createTestSqliteDatabase(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := sqlx.Connect("sqlite3", path)
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	_, err = db.Exec("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO users (name) VALUES ('alice'), ('bob')")
+	require.NoError(t, err)
+	return path
+}
+
+func TestReadOnlySqliteConnection(t *testing.T) {
+	path := createTestSqliteDatabase(t)
+
+	configs := []*DatabaseConfig{
+		{DSN: path, Driver: "sqlite3", ReadOnly: true},
+		{Database: path, Type: "sqlite", ReadOnly: true},
+	}
+	for _, config := range configs {
+		db, err := config.Connect()
+		require.NoError(t, err)
+		assert.True(t, IsReadOnly(db))
+
+		ctx := context.Background()
+		gp := &rowCollector{}
+		err = RunQueryIntoGlaze(ctx, db, "SELECT name FROM users ORDER BY id", []interface{}{}, gp)
+		require.NoError(t, err)
+		require.Len(t, gp.rows, 2)
+		name, _ := gp.rows[0].Get("name")
+		assert.Equal(t, "alice", name)
+
+		// rejected before execution
+		err = RunQueryIntoGlaze(ctx, db, "DELETE FROM users", []interface{}{}, gp)
+		assert.True(t, errors.Is(err, ErrMutatingStatement))
+		err = RunNamedQueryIntoGlaze(ctx, db, "UPDATE users SET name = :name", map[string]interface{}{"name": "eve"}, gp)
+		assert.True(t, errors.Is(err, ErrMutatingStatement))
+
+		// rejected by the database
+		_, err = db.Exec("INSERT INTO users (name) VALUES ('eve')")
+		assert.Error(t, err)
+		_, err = db.Exec("PRAGMA query_only = 0")
+		if err == nil {
+			_, err = db.Exec("INSERT INTO users (name) VALUES ('eve')")
+		}
+		assert.Error(t, err)
+
+		require.NoError(t, db.Close())
+	}
+}
+
+func TestReadWriteSqliteConnection(t *testing.T) {
+	path := createTestSqliteDatabase(t)
+
+	db, err := (&DatabaseConfig{DSN: path, Driver: "sqlite3"}).Connect()
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	assert.False(t, IsReadOnly(db))
+
+	err = RunQueryIntoGlaze(context.Background(), db, "DELETE FROM users RETURNING id", []interface{}{}, &rowCollector{})
+	assert.NoError(t, err)
+}
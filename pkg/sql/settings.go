@@ -27,6 +27,14 @@ type SqlConnectionSettings struct {
 	Repository string `glazed.parameter:"repository"`
 	Dsn        string `glazed.parameter:"dsn"`
 	Driver     string `glazed.parameter:"driver"`
+	ReadOnly   bool   `glazed.parameter:"read-only"`
+
+	MaxOpenConnections    int `glazed.parameter:"max-open-connections"`
+	MaxIdleConnections    int `glazed.parameter:"max-idle-connections"`
+	ConnectionMaxLifetime int `glazed.parameter:"connection-max-lifetime"`
+	ConnectionMaxIdleTime int `glazed.parameter:"connection-max-idle-time"`
+	ConnectTimeout        int `glazed.parameter:"connect-timeout"`
+	StatementTimeout      int `glazed.parameter:"statement-timeout"`
 }
 
 func NewSqlConnectionParameterLayer(
@@ -127,6 +127,9 @@ func CreateTemplate(
 				return s, nil
 			},
 			"sqlSlice": func(query string, args ...interface{}) ([]interface{}, error) {
+				ctx, cancel := WithStatementTimeout(ctx, db)
+				defer cancel()
+
 				_, rows, err := RunQuery(ctx, subQueries, query, args, ps, db)
 				if err != nil {
 					// TODO(manuel, 2023-03-27) This nesting of errors in nested templates becomes quite unpalatable
@@ -165,6 +168,9 @@ func CreateTemplate(
 				return ret, nil
 			},
 			"sqlColumn": func(query string, args ...interface{}) ([]interface{}, error) {
+				ctx, cancel := WithStatementTimeout(ctx, db)
+				defer cancel()
+
 				renderedQuery, rows, err := RunQuery(ctx, subQueries, query, args, ps, db)
 				if err != nil {
 					return nil, errors.Wrapf(err, "Could not run query: %s", renderedQuery)
@@ -193,6 +199,9 @@ func CreateTemplate(
 				return ret, nil
 			},
 			"sqlSingle": func(query string, args ...interface{}) (interface{}, error) {
+				ctx, cancel := WithStatementTimeout(ctx, db)
+				defer cancel()
+
 				renderedQuery, rows, err := RunQuery(ctx, subQueries, query, args, ps, db)
 				if err != nil {
 					return nil, errors.Wrapf(err, "Could not run query: %s", renderedQuery)
@@ -230,6 +239,9 @@ func CreateTemplate(
 				return sqlEltToTemplateValue(ret[0]), nil
 			},
 			"sqlMap": func(query string, args ...interface{}) (interface{}, error) {
+				ctx, cancel := WithStatementTimeout(ctx, db)
+				defer cancel()
+
 				renderedQuery, rows, err := RunQuery(ctx, subQueries, query, args, ps, db)
 				if err != nil {
 					return nil, errors.Wrapf(err, "Could not run query: %s", renderedQuery)